@@ -20,18 +20,24 @@ import (
 	"os"
 	"strings"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/utils/pointer"
 )
 
 const (
-	DefaultNamespace              = "platform-operator-system"
-	DefaultWebhookPort            = 9443
-	DefaultHealthProbeBindAddress = ":8081"
-	DefaultMetricsBindAddress     = ":8080"
-	DefaultLeaderElectionID       = "dcd661b7.mydev.org"
-	DefaultClientConnectionQPS    = 20.0
-	DefaultClientConnectionBurst  = 30
+	DefaultNamespace                 = "platform-operator-system"
+	DefaultWebhookPort               = 9443
+	DefaultHealthProbeBindAddress    = ":8081"
+	DefaultMetricsBindAddress        = ":8080"
+	DefaultLeaderElectionID          = "dcd661b7.mydev.org"
+	DefaultClientConnectionQPS       = 20.0
+	DefaultClientConnectionBurst     = 30
+	DefaultWebhookServiceName        = "platform-operator-webhook-service"
+	DefaultWebhookSecretName         = "platform-operator-webhook-server-cert"
+	DefaultAWSRoleNameTemplate       = "{{.ClusterName}}-{{.Namespace}}-{{.Name}}"
+	DefaultGCPServiceAccountTemplate = "{{.Namespace}}-{{.Name}}"
 )
 
 func addDefaultingFuncs(scheme *runtime.Scheme) error {
@@ -68,6 +74,80 @@ func SetDefaults_Configuration(cfg *OperatorConfig) {
 		*cfg.LeaderElection.LeaderElect && len(cfg.LeaderElection.ResourceName) == 0 {
 		cfg.LeaderElection.ResourceName = DefaultLeaderElectionID
 	}
+	if cfg.Routing.Mode == "" {
+		cfg.Routing.Mode = RoutingModeIngress
+	}
+	if cfg.InternalCertManagement == nil {
+		cfg.InternalCertManagement = &InternalCertManagement{}
+	}
+	if cfg.InternalCertManagement.Enable == nil {
+		cfg.InternalCertManagement.Enable = pointer.Bool(true)
+	}
+	if *cfg.InternalCertManagement.Enable {
+		if cfg.InternalCertManagement.WebhookServiceName == nil {
+			cfg.InternalCertManagement.WebhookServiceName = pointer.String(DefaultWebhookServiceName)
+		}
+		if cfg.InternalCertManagement.WebhookSecretName == nil {
+			cfg.InternalCertManagement.WebhookSecretName = pointer.String(DefaultWebhookSecretName)
+		}
+	}
+	if cfg.Identity.AWS != nil && cfg.Identity.AWS.RoleNameTemplate == "" {
+		cfg.Identity.AWS.RoleNameTemplate = DefaultAWSRoleNameTemplate
+	}
+	if cfg.Identity.GCP != nil && cfg.Identity.GCP.ServiceAccountTemplate == "" {
+		cfg.Identity.GCP.ServiceAccountTemplate = DefaultGCPServiceAccountTemplate
+	}
+	if cfg.Probes.Readiness == nil {
+		cfg.Probes.Readiness = &DefaultProbe{}
+	}
+	for _, probe := range []*DefaultProbe{cfg.Probes.Readiness, cfg.Probes.Liveness, cfg.Probes.Startup} {
+		if probe == nil {
+			continue
+		}
+		if probe.Type == "" {
+			probe.Type = ProbeTypeAuto
+		}
+		if probe.Path == "" {
+			probe.Path = "/"
+		}
+	}
+	if cfg.NetworkPolicies.AllowDNS == nil {
+		cfg.NetworkPolicies.AllowDNS = pointer.Bool(true)
+	}
+	if cfg.Availability.Critical == nil {
+		minAvailable := intstr.FromString("75%")
+		cfg.Availability.Critical = &AvailabilityClassRules{
+			MinAvailable:    &minAvailable,
+			PodAntiAffinity: PodAntiAffinityPreferred,
+			TopologySpread: []TopologySpread{
+				{TopologyKey: corev1.LabelTopologyZone},
+				{TopologyKey: corev1.LabelHostname},
+			},
+		}
+	}
+	if cfg.Availability.Standard == nil {
+		maxUnavailable := intstr.FromInt(1)
+		cfg.Availability.Standard = &AvailabilityClassRules{
+			MaxUnavailable:  &maxUnavailable,
+			PodAntiAffinity: PodAntiAffinityPreferred,
+		}
+	}
+	if cfg.Availability.BestEffort == nil {
+		cfg.Availability.BestEffort = &AvailabilityClassRules{}
+	}
+	for _, rules := range []*AvailabilityClassRules{cfg.Availability.Critical, cfg.Availability.Standard, cfg.Availability.BestEffort} {
+		if rules.PodAntiAffinity == "" {
+			rules.PodAntiAffinity = PodAntiAffinityNone
+		}
+		for i := range rules.TopologySpread {
+			if rules.TopologySpread[i].MaxSkew == 0 {
+				rules.TopologySpread[i].MaxSkew = 1
+			}
+			if rules.TopologySpread[i].WhenUnsatisfiable == "" {
+				rules.TopologySpread[i].WhenUnsatisfiable = corev1.ScheduleAnyway
+			}
+		}
+	}
 	if cfg.ClientConnection == nil {
 		cfg.ClientConnection = &ClientConnection{}
 	}
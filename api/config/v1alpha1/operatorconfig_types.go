@@ -19,7 +19,10 @@ package v1alpha1
 import (
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	configv1alpha1 "k8s.io/component-base/config/v1alpha1"
 )
 
@@ -43,6 +46,41 @@ type OperatorConfig struct {
 	// ClientConnection provides additional configuration options for Kubernetes
 	// API server client.
 	ClientConnection *ClientConnection `json:"clientConnection,omitempty"`
+
+	// InternalCertManagement is configuration for internalCertManagement
+	InternalCertManagement *InternalCertManagement `json:"internalCertManagement,omitempty"`
+
+	// Routing contains the configuration for the external routing of Workloads
+	// +optional
+	Routing Routing `json:"routing,omitempty"`
+
+	// ImagePullSecrets contains the image pull secrets of Workload ServiceAccounts
+	// +optional
+	ImagePullSecrets ImagePullSecrets `json:"imagePullSecrets,omitempty"`
+
+	// Availability contains the rules of the Workload availability classes
+	// +optional
+	Availability Availability `json:"availability,omitempty"`
+
+	// NetworkPolicies contains the configuration of the NetworkPolicies of Workloads
+	// +optional
+	NetworkPolicies NetworkPolicies `json:"networkPolicies,omitempty"`
+
+	// Permissions contains the permissions Workloads may grant to their ServiceAccounts
+	// +optional
+	Permissions Permissions `json:"permissions,omitempty"`
+
+	// Identity contains the provider-wide defaults of Workload cloud identities
+	// +optional
+	Identity Identity `json:"identity,omitempty"`
+
+	// Probes contains the default probes and shutdown settings of Workload containers
+	// +optional
+	Probes Probes `json:"probes,omitempty"`
+
+	// Rollouts contains the configuration of progressive Workload rollouts
+	// +optional
+	Rollouts Rollouts `json:"rollouts,omitempty"`
 }
 
 type ControllerManager struct {
@@ -138,6 +176,23 @@ type ControllerConfigurationSpec struct {
 	CacheSyncTimeout *time.Duration `json:"cacheSyncTimeout,omitempty"`
 }
 
+type InternalCertManagement struct {
+	// Enable controls whether to enable internal cert management or not.
+	// Defaults to true. If you want to use a third-party management, e.g. cert-manager,
+	// set it to false.
+	Enable *bool `json:"enable,omitempty"`
+
+	// WebhookServiceName is the name of the Service used as part of the DNSName.
+	// Defaults to platform-operator-webhook-service.
+	WebhookServiceName *string `json:"webhookServiceName,omitempty"`
+
+	// WebhookSecretName is the name of the Secret used to store CA and server certs.
+	// Defaults to platform-operator-webhook-server-cert. The manager Role in
+	// config/rbac grants access to the Secret by name, renaming it requires
+	// editing the Role as well.
+	WebhookSecretName *string `json:"webhookSecretName,omitempty"`
+}
+
 type ClientConnection struct {
 	// QPS controls the number of queries per second allowed for K8S api server
 	// connection.
@@ -146,3 +201,314 @@ type ClientConnection struct {
 	// Burst allows extra queries to accumulate when a client is exceeding its rate.
 	Burst *int32 `json:"burst,omitempty"`
 }
+
+// RoutingMode selects the networking objects generated for Workload hostnames.
+type RoutingMode string
+
+const (
+	// RoutingModeIngress generates networking.k8s.io/v1 Ingress objects.
+	RoutingModeIngress RoutingMode = "Ingress"
+
+	// RoutingModeGatewayAPI generates gateway.networking.k8s.io/v1beta1 HTTPRoute objects.
+	RoutingModeGatewayAPI RoutingMode = "GatewayAPI"
+)
+
+// Routing defines how Workloads are exposed outside of the cluster.
+type Routing struct {
+	// Mode selects the kind of objects generated for Workload hostnames,
+	// either Ingress or GatewayAPI. Defaults to Ingress.
+	// +optional
+	Mode RoutingMode `json:"mode,omitempty"`
+
+	// IngressClassName is the IngressClass set on generated Ingress objects.
+	// If not set, the cluster default IngressClass is used.
+	// +optional
+	IngressClassName *string `json:"ingressClassName,omitempty"`
+
+	// Gateway is the Gateway that generated HTTPRoute objects attach to.
+	// Required when mode is GatewayAPI.
+	// +optional
+	Gateway *GatewayReference `json:"gateway,omitempty"`
+}
+
+// GatewayReference identifies the Gateway listeners HTTPRoutes attach to.
+type GatewayReference struct {
+	// Name is the name of the Gateway.
+	Name string `json:"name"`
+
+	// Namespace is the namespace of the Gateway.
+	Namespace string `json:"namespace"`
+
+	// SectionName is the name of the listener used by routes without TLS.
+	// If not set, routes attach to all listeners of the Gateway.
+	// +optional
+	SectionName string `json:"sectionName,omitempty"`
+
+	// TLSSectionName is the name of the listener used by routes with TLS.
+	// The listener is expected to terminate TLS for the Workload hostnames.
+	// If not set, SectionName is used.
+	// +optional
+	TLSSectionName string `json:"tlsSectionName,omitempty"`
+}
+
+// ImagePullSecrets defines the cluster-wide image pull secrets of Workloads.
+type ImagePullSecrets struct {
+	// Names of the image pull secrets set on the ServiceAccount of every
+	// Workload, unless the Workload opts out of them.
+	// +optional
+	Names []string `json:"names,omitempty"`
+
+	// Copy copies the named secrets from the operator namespace into the
+	// namespace of each Workload and keeps the copies in sync. When not set,
+	// the secrets are expected to exist in the Workload namespaces.
+	// +optional
+	Copy bool `json:"copy,omitempty"`
+}
+
+// NetworkPolicies defines the NetworkPolicies generated for Workloads.
+type NetworkPolicies struct {
+	// DefaultDeny denies all traffic of every Workload that is not declared in
+	// its network spec. When not set, only the directions a Workload declares
+	// peers for are restricted.
+	// +optional
+	DefaultDeny bool `json:"defaultDeny,omitempty"`
+
+	// AllowDNS allows the egress traffic to port 53 of restricted Workloads.
+	// Defaults to true.
+	// +optional
+	AllowDNS *bool `json:"allowDNS,omitempty"`
+
+	// IngressNamespaces are the namespaces allowed to connect to Workloads
+	// with an ingress, e.g. the namespace of the ingress controller. With
+	// Gateway API routing, the namespace of the Gateway is always allowed.
+	// +optional
+	IngressNamespaces []string `json:"ingressNamespaces,omitempty"`
+}
+
+// Rollouts defines the progressive rollouts of Workloads.
+type Rollouts struct {
+	// PrometheusURL is the address of the Prometheus-compatible API used for
+	// the analysis of canary rollouts, e.g. http://prometheus.monitoring:9090.
+	// Required for Workloads with canary analysis.
+	// +optional
+	PrometheusURL string `json:"prometheusURL,omitempty"`
+}
+
+// ProbeType selects how a default probe checks a container.
+type ProbeType string
+
+const (
+	// ProbeTypeAuto uses an HTTP GET when the name of the first container
+	// port starts with http, and a TCP connection otherwise.
+	ProbeTypeAuto ProbeType = "Auto"
+
+	// ProbeTypeHTTP sends an HTTP GET to the first container port.
+	ProbeTypeHTTP ProbeType = "HTTP"
+
+	// ProbeTypeTCP opens a TCP connection to the first container port.
+	ProbeTypeTCP ProbeType = "TCP"
+)
+
+// Probes defines the defaults added to Workload containers that do not set
+// their own. Default probes are only added to long-running containers with
+// at least one port.
+type Probes struct {
+	// Readiness is the default readiness probe.
+	// Defaults to an Auto probe of the path /.
+	// +optional
+	Readiness *DefaultProbe `json:"readiness,omitempty"`
+
+	// Liveness is the default liveness probe. There is none by default.
+	// +optional
+	Liveness *DefaultProbe `json:"liveness,omitempty"`
+
+	// Startup is the default startup probe. There is none by default.
+	// +optional
+	Startup *DefaultProbe `json:"startup,omitempty"`
+
+	// PreStopSleepSeconds delays the termination of containers so that their
+	// pods are removed from the Service endpoints first. It requires a sleep
+	// binary in the container image. There is no delay by default.
+	// +optional
+	PreStopSleepSeconds *int32 `json:"preStopSleepSeconds,omitempty"`
+
+	// TerminationGracePeriodSeconds is the default duration pods are given to
+	// terminate gracefully.
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+}
+
+// DefaultProbe defines a probe of the first TCP port of a Workload container.
+// Containers without a TCP port get no default probes.
+type DefaultProbe struct {
+	// Type is one of Auto, HTTP or TCP. Defaults to Auto.
+	// +optional
+	Type ProbeType `json:"type,omitempty"`
+
+	// Path of HTTP probes. Defaults to /.
+	// +optional
+	Path string `json:"path,omitempty"`
+
+	// Number of seconds after the container has started before the probe is initiated.
+	// +optional
+	InitialDelaySeconds int32 `json:"initialDelaySeconds,omitempty"`
+
+	// How often in seconds to perform the probe.
+	// +optional
+	PeriodSeconds int32 `json:"periodSeconds,omitempty"`
+
+	// Number of seconds after which the probe times out.
+	// +optional
+	TimeoutSeconds int32 `json:"timeoutSeconds,omitempty"`
+
+	// Minimum consecutive failures for the probe to be considered failed.
+	// +optional
+	FailureThreshold int32 `json:"failureThreshold,omitempty"`
+}
+
+// Identity defines the cloud identity defaults of Workloads. The naming
+// templates are Go templates executed with the ClusterName, Namespace and
+// Name of a Workload.
+type Identity struct {
+	// OIDCIssuer is the issuer URL of the ServiceAccount tokens of this cluster.
+	// +optional
+	OIDCIssuer string `json:"oidcIssuer,omitempty"`
+
+	// AWS contains the defaults of IAM roles for service accounts.
+	// +optional
+	AWS *AWSIdentity `json:"aws,omitempty"`
+
+	// GCP contains the defaults of GKE Workload Identity.
+	// +optional
+	GCP *GCPIdentity `json:"gcp,omitempty"`
+
+	// Azure contains the defaults of Azure AD workload identity.
+	// +optional
+	Azure *AzureIdentity `json:"azure,omitempty"`
+}
+
+// AWSIdentity defines the defaults of AWS workload identities.
+type AWSIdentity struct {
+	// AccountID is the AWS account of the IAM roles.
+	AccountID string `json:"accountID"`
+
+	// RoleNameTemplate is the name of the IAM role of a Workload without a role.
+	// Defaults to {{.ClusterName}}-{{.Namespace}}-{{.Name}}.
+	// +optional
+	RoleNameTemplate string `json:"roleNameTemplate,omitempty"`
+
+	// STSRegionalEndpoints makes the pods use the regional STS endpoint.
+	// +optional
+	STSRegionalEndpoints bool `json:"stsRegionalEndpoints,omitempty"`
+}
+
+// GCPIdentity defines the defaults of GCP workload identities.
+type GCPIdentity struct {
+	// ProjectID is the GCP project of the service accounts.
+	ProjectID string `json:"projectID"`
+
+	// ServiceAccountTemplate is the name of the GCP service account of a
+	// Workload without a role. Defaults to {{.Namespace}}-{{.Name}}.
+	// +optional
+	ServiceAccountTemplate string `json:"serviceAccountTemplate,omitempty"`
+}
+
+// AzureIdentity defines the defaults of Azure workload identities.
+type AzureIdentity struct {
+	// TenantID is the Azure AD tenant of the managed identities.
+	// If not set, the tenant of the cluster is used.
+	// +optional
+	TenantID string `json:"tenantID,omitempty"`
+}
+
+// Permissions defines the allowlist of the permissions of Workloads. The
+// operator can only grant what it holds itself, so the workload-permissions
+// ClusterRole of config/rbac has to hold the allowed rules and bind the
+// approved ClusterRoles as well.
+type Permissions struct {
+	// AllowedRules are the rules Workloads may grant. Every rule of a
+	// Workload must be covered by one of these rules. When empty, Workloads
+	// can not declare rules.
+	// +optional
+	AllowedRules []rbacv1.PolicyRule `json:"allowedRules,omitempty"`
+
+	// ApprovedClusterRoles are the names of the ClusterRoles Workloads may
+	// bind in their namespace.
+	// +optional
+	ApprovedClusterRoles []string `json:"approvedClusterRoles,omitempty"`
+}
+
+// Availability defines the rules of each Workload availability class.
+type Availability struct {
+	// Critical are the rules of critical Workloads.
+	// Defaults to a minimum of 75% available pods, preferred anti-affinity
+	// and spreading over zones and nodes.
+	// +optional
+	Critical *AvailabilityClassRules `json:"critical,omitempty"`
+
+	// Standard are the rules of standard Workloads.
+	// Defaults to at most one unavailable pod and preferred anti-affinity.
+	// +optional
+	Standard *AvailabilityClassRules `json:"standard,omitempty"`
+
+	// BestEffort are the rules of best-effort Workloads.
+	// Defaults to no disruption budget and no spreading.
+	// +optional
+	BestEffort *AvailabilityClassRules `json:"bestEffort,omitempty"`
+}
+
+// PodAntiAffinityMode selects the pod anti-affinity of Workload pods.
+type PodAntiAffinityMode string
+
+const (
+	// PodAntiAffinityNone does not set a pod anti-affinity.
+	PodAntiAffinityNone PodAntiAffinityMode = "None"
+
+	// PodAntiAffinityPreferred prefers to schedule the pods of a Workload on
+	// different nodes.
+	PodAntiAffinityPreferred PodAntiAffinityMode = "Preferred"
+
+	// PodAntiAffinityRequired schedules the pods of a Workload on different
+	// nodes only. Pods stay pending when there are not enough nodes.
+	PodAntiAffinityRequired PodAntiAffinityMode = "Required"
+)
+
+// AvailabilityClassRules defines the disruption budget and the pod spreading
+// of the Workloads of an availability class.
+type AvailabilityClassRules struct {
+	// MinAvailable is the number or percentage of pods that must stay
+	// available during voluntary disruptions. Takes precedence over
+	// MaxUnavailable. No PodDisruptionBudget is created when neither is set.
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// MaxUnavailable is the number or percentage of pods that may be
+	// unavailable during voluntary disruptions.
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+
+	// PodAntiAffinity is one of None, Preferred or Required.
+	// Defaults to None.
+	// +optional
+	PodAntiAffinity PodAntiAffinityMode `json:"podAntiAffinity,omitempty"`
+
+	// TopologySpread spreads the pods of a Workload over the given topology domains.
+	// +optional
+	TopologySpread []TopologySpread `json:"topologySpread,omitempty"`
+}
+
+// TopologySpread defines how Workload pods are spread over a topology domain.
+type TopologySpread struct {
+	// TopologyKey is the node label of the topology domain, e.g. topology.kubernetes.io/zone.
+	TopologyKey string `json:"topologyKey"`
+
+	// MaxSkew is the maximum difference of the number of pods between domains.
+	// Defaults to 1.
+	// +optional
+	MaxSkew int32 `json:"maxSkew,omitempty"`
+
+	// WhenUnsatisfiable is DoNotSchedule or ScheduleAnyway.
+	// Defaults to ScheduleAnyway.
+	// +optional
+	WhenUnsatisfiable corev1.UnsatisfiableConstraintAction `json:"whenUnsatisfiable,omitempty"`
+}
@@ -18,38 +18,1090 @@ package v1
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
 // NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
 
 // WorkloadSpec defines the desired state of Workload
+// +kubebuilder:validation:XValidation:rule="!has(self.ingress) || has(self.service)",message="ingress requires service to be set"
+// +kubebuilder:validation:XValidation:rule="(has(self.kind) && self.kind == 'CronJob') == has(self.schedule)",message="schedule must be set for CronJob workloads only"
+// +kubebuilder:validation:XValidation:rule="!has(self.kind) || self.kind == 'Deployment' || (!has(self.service) && !has(self.autoscaling))",message="service and autoscaling are only supported for Deployment workloads"
+// +kubebuilder:validation:XValidation:rule="!has(self.job) || (has(self.kind) && self.kind != 'Deployment')",message="job may only be set for Job and CronJob workloads"
+// +kubebuilder:validation:XValidation:rule="!has(self.storage) || !has(self.kind) || self.kind == 'Deployment'",message="storage is only supported for Deployment workloads"
+// +kubebuilder:validation:XValidation:rule="!has(self.rollout) || self.rollout.strategy == 'rolling-update' || ((!has(self.kind) || self.kind == 'Deployment') && !has(self.storage))",message="rollout strategies other than rolling-update are only supported for Deployment workloads without storage"
+// +kubebuilder:validation:XValidation:rule="has(self.container) || ((!has(self.kind) || self.kind == 'Deployment') && !has(self.storage) && !has(self.autoscaling) && !has(self.rollout))",message="container is required for Job and CronJob workloads, storage, autoscaling and rollout"
 type WorkloadSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// Kind is the kind of object running the pods of this workload, one of
+	// Deployment, Job or CronJob. Defaults to Deployment.
+	// +kubebuilder:default=Deployment
+	// +optional
+	Kind WorkloadKind `json:"kind,omitempty"`
+
+	// Schedule of a CronJob workload in Cron format.
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// Job configures the runs of Job and CronJob workloads.
+	// +optional
+	Job *JobSpec `json:"job,omitempty"`
+
 	// The name of the service account to use to run this workload.
 	// Defaults to the workload name.
 	// +optional
 	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// ImagePullSecrets configures the image pull secrets set on the
+	// ServiceAccount of this workload.
+	// +optional
+	ImagePullSecrets *ImagePullSecretsSpec `json:"imagePullSecrets,omitempty"`
+
+	// Identity binds the ServiceAccount of this workload to a cloud identity
+	// through workload identity federation.
+	// +optional
+	Identity *IdentitySpec `json:"identity,omitempty"`
+
+	// Permissions are granted to the ServiceAccount of this workload in the
+	// namespace of the workload, through an owned Role and RoleBindings.
+	// +optional
+	Permissions *PermissionsSpec `json:"permissions,omitempty"`
+
+	// Number of desired pods. This is a pointer to distinguish between explicit
+	// zero and not specified. Ignored when autoscaling is set.
+	// Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	Replicas *int32 `json:"replicas,omitempty"`
+
+	// Autoscaling scales the pods of this workload with a
+	// HorizontalPodAutoscaler. The replica count is then owned by the
+	// HorizontalPodAutoscaler and spec.replicas is ignored.
+	// +optional
+	Autoscaling *AutoscalingSpec `json:"autoscaling,omitempty"`
+
+	// The container that runs this workload. Without a container, only the
+	// ServiceAccount and the other non-pod objects of the workload are created.
+	// +optional
+	Container *ContainerSpec `json:"container,omitempty"`
+
+	// Duration in seconds the pods of this workload are given to terminate
+	// gracefully. Defaults to the value configured in the operator, or to
+	// 30 seconds.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// Config is rendered into a ConfigMap owned by this workload and injected
+	// into its pods. Changing it rolls out the pods.
+	// +optional
+	Config *ConfigSpec `json:"config,omitempty"`
+
+	// SecretRefs are Secrets in the namespace of this workload injected into
+	// its pods. Changing their data rolls out the pods. The workload is
+	// Degraded while a Secret that is not optional does not exist.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	SecretRefs []SecretReference `json:"secretRefs,omitempty"`
+
+	// Rollout determines how changes of the container image are rolled out.
+	// Defaults to a rolling update.
+	// +optional
+	Rollout *RolloutSpec `json:"rollout,omitempty"`
+
+	// Storage gives every pod of this workload its own persistent volumes.
+	// Workloads with storage are run by a StatefulSet with a headless
+	// Service instead of a Deployment.
+	// +optional
+	Storage *StorageSpec `json:"storage,omitempty"`
+
+	// Service exposes the pods of this workload through an owned Service.
+	// No Service is created when this is not provided.
+	// +optional
+	Service *ServiceSpec `json:"service,omitempty"`
+
+	// Ingress routes external HTTP traffic for the given hostnames to the
+	// Service of this workload. Depending on the operator configuration an
+	// Ingress or a Gateway API HTTPRoute is created.
+	// +optional
+	Ingress *IngressSpec `json:"ingress,omitempty"`
+
+	// Network declares the traffic allowed to and from the pods of this
+	// workload. The operator renders it into a NetworkPolicy.
+	// +optional
+	Network *NetworkSpec `json:"network,omitempty"`
+
+	// Availability is the availability class of this workload, one of
+	// critical, standard or best-effort. The class selects the
+	// PodDisruptionBudget and the pod spreading configured in the operator.
+	// A PodDisruptionBudget that allows no disruption, e.g. of a critical
+	// workload with a single replica, blocks node drains and is reported in
+	// the PodDisruptionBudgetReady condition. Defaults to standard.
+	// +kubebuilder:default=standard
+	// +optional
+	Availability AvailabilityClass `json:"availability,omitempty"`
+
+	// DeletionPolicy determines what happens to the objects created for this
+	// workload when it is deleted. One of Delete, Orphan or Retain.
+	// Defaults to Delete.
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+
+	// DriftPolicy determines what happens to fields of the objects of this
+	// workload that were changed or removed by others. With correct, the
+	// changes are reverted; with report, the changed fields are left as they
+	// are while the other fields are still updated, but changes made while
+	// the workload was paused are reverted once it is resumed. Fields added
+	// by others, e.g. annotations of other controllers, are never removed.
+	// Drift is reported in the Drifted condition either way. Defaults to
+	// correct.
+	// +kubebuilder:default=correct
+	// +optional
+	DriftPolicy DriftPolicy `json:"driftPolicy,omitempty"`
+
+	// Paused stops the reconciliation of this workload, so that its objects
+	// can be changed by hand. They are corrected once it is resumed, also
+	// with the report drift policy. The annotation platform.mydev.org/reconcile: disabled pauses a workload
+	// as well.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
+
+	// RevisionHistoryLimit is the number of WorkloadRevisions kept to roll
+	// back to. Defaults to 10.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=10
+	// +optional
+	RevisionHistoryLimit *int32 `json:"revisionHistoryLimit,omitempty"`
+
+	// RollbackTo restores the spec of the WorkloadRevision with the given
+	// number, or of the previous revision when 0. Paused is kept as it is.
+	// It is cleared once the spec is restored.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RollbackTo *int64 `json:"rollbackTo,omitempty"`
+}
+
+// WorkloadKind is the kind of object running the pods of a Workload.
+// +kubebuilder:validation:Enum=Deployment;Job;CronJob
+type WorkloadKind string
+
+const (
+	// WorkloadKindDeployment runs long-running pods with a Deployment.
+	WorkloadKindDeployment WorkloadKind = "Deployment"
+
+	// WorkloadKindJob runs pods to completion once with a Job.
+	WorkloadKindJob WorkloadKind = "Job"
+
+	// WorkloadKindCronJob runs pods to completion on a schedule with a CronJob.
+	WorkloadKindCronJob WorkloadKind = "CronJob"
+)
+
+// ConcurrencyPolicy describes how concurrent runs of a CronJob workload are treated.
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyPolicyAllow allows runs to overlap.
+	ConcurrencyPolicyAllow ConcurrencyPolicy = "Allow"
+
+	// ConcurrencyPolicyForbid skips a run while the previous one is still running.
+	ConcurrencyPolicyForbid ConcurrencyPolicy = "Forbid"
+
+	// ConcurrencyPolicyReplace replaces a run that is still running.
+	ConcurrencyPolicyReplace ConcurrencyPolicy = "Replace"
+)
+
+// JobSpec defines the runs of Job and CronJob Workloads
+type JobSpec struct {
+	// RestartPolicy of the pods, OnFailure or Never.
+	// Defaults to OnFailure.
+	// +kubebuilder:validation:Enum=OnFailure;Never
+	// +kubebuilder:default=OnFailure
+	// +optional
+	RestartPolicy corev1.RestartPolicy `json:"restartPolicy,omitempty"`
+
+	// Number of retries before a run is marked as failed.
+	// Defaults to 6.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	BackoffLimit *int32 `json:"backoffLimit,omitempty"`
+
+	// Duration in seconds a run may be active before it is terminated.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// ConcurrencyPolicy of a CronJob workload, one of Allow, Forbid or Replace.
+	// Defaults to Forbid.
+	// +kubebuilder:default=Forbid
+	// +optional
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	// Deadline in seconds for starting a run of a CronJob workload that
+	// missed its scheduled time.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+
+	// Number of successful finished runs of a CronJob workload to keep.
+	// Defaults to 3.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SuccessfulJobsHistoryLimit *int32 `json:"successfulJobsHistoryLimit,omitempty"`
+
+	// Number of failed finished runs of a CronJob workload to keep.
+	// Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailedJobsHistoryLimit *int32 `json:"failedJobsHistoryLimit,omitempty"`
+
+	// Duration in seconds after which the finished runs of a CronJob workload
+	// are deleted. A Job workload is kept, so that it does not run again.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+}
+
+// DeletionPolicy describes what happens to the objects of a Workload when
+// the Workload is deleted.
+// +kubebuilder:validation:Enum=Delete;Orphan;Retain
+type DeletionPolicy string
+
+const (
+	// DeletionPolicyDelete deletes all objects created for the workload.
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+
+	// DeletionPolicyOrphan keeps all objects created for the workload and
+	// removes the owner references to the workload.
+	DeletionPolicyOrphan DeletionPolicy = "Orphan"
+
+	// DeletionPolicyRetain keeps the ServiceAccount of the workload and the
+	// image pull secrets copied for it, removing their owner references, and
+	// deletes all other objects.
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+)
+
+// DriftPolicy describes how changes of the objects of a Workload by others
+// are treated.
+// +kubebuilder:validation:Enum=correct;report
+type DriftPolicy string
+
+const (
+	// DriftPolicyCorrect reverts fields changed or removed by others to the
+	// desired state.
+	DriftPolicyCorrect DriftPolicy = "correct"
+
+	// DriftPolicyReport leaves the fields changed by others as they are and
+	// only reports the drift.
+	DriftPolicyReport DriftPolicy = "report"
+)
+
+// AvailabilityClass describes how well the pods of a Workload are protected
+// against voluntary disruptions, such as node drains.
+// +kubebuilder:validation:Enum=critical;standard;best-effort
+type AvailabilityClass string
+
+const (
+	// AvailabilityClassCritical is for workloads that must stay available
+	// during node drains, even with a single replica.
+	AvailabilityClassCritical AvailabilityClass = "critical"
+
+	// AvailabilityClassStandard is for workloads that tolerate losing some
+	// of their pods during node drains.
+	AvailabilityClassStandard AvailabilityClass = "standard"
+
+	// AvailabilityClassBestEffort is for workloads without disruption protection.
+	AvailabilityClassBestEffort AvailabilityClass = "best-effort"
+)
+
+// RolloutStrategy is the way image changes of a Workload are rolled out.
+// +kubebuilder:validation:Enum=rolling-update;canary;blue-green
+type RolloutStrategy string
+
+const (
+	// RolloutStrategyRollingUpdate replaces the pods of the workload with a
+	// rolling update of its Deployment.
+	RolloutStrategyRollingUpdate RolloutStrategy = "rolling-update"
+
+	// RolloutStrategyCanary runs the new image in a second, small Deployment
+	// and shifts traffic to it in steps before it is promoted.
+	RolloutStrategyCanary RolloutStrategy = "canary"
+
+	// RolloutStrategyBlueGreen runs every change in a second Deployment next
+	// to the active one and switches the Service over when it is promoted.
+	RolloutStrategyBlueGreen RolloutStrategy = "blue-green"
+)
+
+// RolloutSpec defines how image changes of a Workload are rolled out
+// +kubebuilder:validation:XValidation:rule="self.strategy != 'canary' || has(self.canary)",message="canary must be set for the canary strategy"
+type RolloutSpec struct {
+	// Strategy is one of rolling-update, canary or blue-green. Switching to
+	// or from blue-green replaces the Deployment of the workload, the
+	// previous Deployment is kept until its replacement is rolled out.
+	// Defaults to rolling-update.
+	// +kubebuilder:default=rolling-update
+	// +optional
+	Strategy RolloutStrategy `json:"strategy,omitempty"`
+
+	// Canary configures the steps and the analysis of canary rollouts.
+	// +optional
+	Canary *CanarySpec `json:"canary,omitempty"`
+
+	// BlueGreen configures blue/green rollouts.
+	// +optional
+	BlueGreen *BlueGreenSpec `json:"blueGreen,omitempty"`
+}
+
+// BlueGreenSpec defines the blue/green rollout of a Workload. Changes run in
+// the preview color and are exposed on the <name>-preview Service until the
+// Workload is annotated with platform.mydev.org/promote. The previous color
+// is kept, so that the annotation platform.mydev.org/rollback switches back.
+type BlueGreenSpec struct {
+	// PreviousReplicas is the number of replicas the previous color keeps for
+	// a rollback. Defaults to the replicas of the workload.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PreviousReplicas *int32 `json:"previousReplicas,omitempty"`
+}
+
+// CanarySpec defines the canary rollout of a Workload
+type CanarySpec struct {
+	// Steps shift traffic to the canary in increasing weights. The canary is
+	// promoted after the last step.
+	// +kubebuilder:validation:MinItems=1
+	Steps []CanaryStep `json:"steps"`
+
+	// Analysis aborts the canary when its error rate is too high. A query
+	// without data is inconclusive and holds the canary at its step. Without
+	// analysis, the canary is only checked for readiness.
+	// +optional
+	Analysis *CanaryAnalysis `json:"analysis,omitempty"`
+}
+
+// CanaryStep defines a step of a canary rollout
+type CanaryStep struct {
+	// Weight is the percentage of traffic sent to the canary. With Gateway
+	// API routing the weight is set on the HTTPRoute; otherwise the replicas
+	// of the workload are split between the canary and the stable pods, so
+	// the weight is rounded to whole pods. A workload with autoscaling keeps
+	// all its stable replicas, so the weight is only approximate.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	Weight int32 `json:"weight"`
+
+	// PauseSeconds is the duration the canary runs at the weight of this
+	// step before the next step.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	PauseSeconds int32 `json:"pauseSeconds,omitempty"`
+}
+
+// CanaryAnalysis defines the metric checked during canary rollouts
+type CanaryAnalysis struct {
+	// Query is a PromQL query returning the error rate of the canary as a
+	// single value, evaluated against the Prometheus-compatible endpoint
+	// configured in the operator. It is a Go template with the fields
+	// Namespace, Name and Canary, the name of the canary Deployment.
+	Query string `json:"query"`
+
+	// MaxErrorRate is the highest error rate, e.g. 0.05, at which the
+	// canary is still promoted.
+	MaxErrorRate resource.Quantity `json:"maxErrorRate"`
+
+	// IntervalSeconds between two evaluations of the query.
+	// Defaults to 30.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=30
+	// +optional
+	IntervalSeconds int32 `json:"intervalSeconds,omitempty"`
+}
+
+// ImagePullSecretsSpec defines the image pull secrets of a Workload
+type ImagePullSecretsSpec struct {
+	// Secrets in the namespace of the workload added to its ServiceAccount.
+	// +optional
+	Secrets []corev1.LocalObjectReference `json:"secrets,omitempty"`
+
+	// InheritDefaults adds the image pull secrets configured in the operator
+	// as well. Set to false to only use the secrets of this workload.
+	// Defaults to true.
+	// +kubebuilder:default=true
+	// +optional
+	InheritDefaults *bool `json:"inheritDefaults,omitempty"`
 }
 
+// IdentityProvider is the cloud provider of a workload identity.
+// +kubebuilder:validation:Enum=AWS;GCP;Azure
+type IdentityProvider string
+
+const (
+	// IdentityProviderAWS uses IAM roles for service accounts (IRSA).
+	IdentityProviderAWS IdentityProvider = "AWS"
+
+	// IdentityProviderGCP uses GKE Workload Identity.
+	IdentityProviderGCP IdentityProvider = "GCP"
+
+	// IdentityProviderAzure uses Azure AD workload identity.
+	IdentityProviderAzure IdentityProvider = "Azure"
+)
+
+// IdentitySpec defines the cloud identity of a Workload
+// +kubebuilder:validation:XValidation:rule="self.provider != 'Azure' || has(self.role)",message="role is required for Azure"
+type IdentitySpec struct {
+	// Provider is the cloud provider of the identity, one of AWS, GCP or Azure.
+	Provider IdentityProvider `json:"provider"`
+
+	// Role is the cloud principal the pods act as: an IAM role name or ARN
+	// for AWS, a service account name or email for GCP, and the client ID of
+	// the managed identity for Azure. For AWS and GCP, it defaults to the
+	// naming template configured in the operator.
+	// +optional
+	Role string `json:"role,omitempty"`
+}
+
+// PermissionsSpec defines the namespaced permissions of a Workload
+type PermissionsSpec struct {
+	// Rules granted to the ServiceAccount. Each rule must be covered by the
+	// rules allowed in the operator configuration.
+	// +optional
+	Rules []rbacv1.PolicyRule `json:"rules,omitempty"`
+
+	// ClusterRoles are names of ClusterRoles bound to the ServiceAccount in
+	// the namespace of the workload, by RoleBindings named
+	// <workload>-clusterrole-<clusterrole>. Only the ClusterRoles approved in
+	// the operator configuration can be used.
+	// +optional
+	ClusterRoles []string `json:"clusterRoles,omitempty"`
+}
+
+// AutoscalingSpec defines the horizontal autoscaling of a Workload
+// +kubebuilder:validation:XValidation:rule="!has(self.minReplicas) || self.minReplicas <= self.maxReplicas",message="minReplicas must not be greater than maxReplicas"
+type AutoscalingSpec struct {
+	// The lower limit for the number of replicas.
+	// Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=1
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+
+	// The upper limit for the number of replicas.
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+
+	// Target average CPU utilization of the pods, as a percentage of the
+	// requested CPU.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetCPUUtilizationPercentage *int32 `json:"targetCPUUtilizationPercentage,omitempty"`
+
+	// Target average memory utilization of the pods, as a percentage of the
+	// requested memory.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TargetMemoryUtilizationPercentage *int32 `json:"targetMemoryUtilizationPercentage,omitempty"`
+
+	// Custom per-pod metrics served by the custom metrics API.
+	// When no target is set, the pods are scaled at 80% CPU utilization.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	CustomMetrics []CustomMetricTarget `json:"customMetrics,omitempty"`
+
+	// Number of seconds for which past recommendations are considered while
+	// scaling down. Defaults to 300 seconds when not provided.
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=3600
+	// +optional
+	ScaleDownStabilizationWindowSeconds *int32 `json:"scaleDownStabilizationWindowSeconds,omitempty"`
+}
+
+// CustomMetricTarget defines the target of a custom per-pod metric
+type CustomMetricTarget struct {
+	// Name of the metric.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Selector narrows down the series of the metric.
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
+	// Target value of the metric averaged across the pods.
+	AverageValue resource.Quantity `json:"averageValue"`
+}
+
+// ContainerSpec defines the container that runs a Workload
+type ContainerSpec struct {
+	// Container image name.
+	// +kubebuilder:validation:MinLength=1
+	Image string `json:"image"`
+
+	// Entrypoint array. Not executed within a shell.
+	// The container image's ENTRYPOINT is used if this is not provided.
+	// +optional
+	Command []string `json:"command,omitempty"`
+
+	// Arguments to the entrypoint.
+	// The container image's CMD is used if this is not provided.
+	// +optional
+	Args []string `json:"args,omitempty"`
+
+	// List of environment variables to set in the container.
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// List of ports to expose from the container.
+	// +optional
+	Ports []corev1.ContainerPort `json:"ports,omitempty"`
+
+	// Compute Resources required by this container.
+	// +optional
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// Periodic probe of container liveness. The container is restarted if
+	// the probe fails.
+	// +optional
+	LivenessProbe *corev1.Probe `json:"livenessProbe,omitempty"`
+
+	// Periodic probe of container service readiness. The pod is removed from
+	// the Service endpoints if the probe fails.
+	// +optional
+	ReadinessProbe *corev1.Probe `json:"readinessProbe,omitempty"`
+
+	// Probe that must succeed before the other probes of the container start.
+	// +optional
+	StartupProbe *corev1.Probe `json:"startupProbe,omitempty"`
+
+	// DefaultProbes adds the probes configured in the operator for the probes
+	// that are not set here. They probe the first TCP port of the container.
+	// Defaults to true.
+	// +kubebuilder:default=true
+	// +optional
+	DefaultProbes *bool `json:"defaultProbes,omitempty"`
+
+	// Actions taken in response to container lifecycle events, such as a
+	// preStop hook for graceful shutdown. The preStop hook configured in the
+	// operator is used when this has none.
+	// +optional
+	Lifecycle *corev1.Lifecycle `json:"lifecycle,omitempty"`
+}
+
+// ConfigSpec defines the configuration of a Workload
+type ConfigSpec struct {
+	// Env are key/values set as environment variables of the container.
+	// +optional
+	Env map[string]string `json:"env,omitempty"`
+
+	// Files are mounted into the container at mountPath.
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Files []ConfigFile `json:"files,omitempty"`
+
+	// MountPath is the directory the files are mounted at.
+	// Defaults to /etc/config.
+	// +kubebuilder:default=/etc/config
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+}
+
+// ConfigFile defines a file of the configuration of a Workload
+type ConfigFile struct {
+	// Name of the file. Must not be the name of an env entry.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Content of the file.
+	Content string `json:"content"`
+}
+
+// SecretReference defines a Secret injected into the pods of a Workload
+type SecretReference struct {
+	// Name of the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// MountPath is the directory the keys of the Secret are mounted at as
+	// files. When not set, the keys are set as environment variables.
+	// +optional
+	MountPath string `json:"mountPath,omitempty"`
+
+	// Optional allows the pods to start when the Secret does not exist.
+	// +optional
+	Optional bool `json:"optional,omitempty"`
+}
+
+// VolumeRetentionPolicy describes what happens to the persistent volume claims
+// of a Workload.
+// +kubebuilder:validation:Enum=Retain;Delete
+type VolumeRetentionPolicy string
+
+const (
+	// VolumeRetentionPolicyRetain keeps the persistent volume claims.
+	VolumeRetentionPolicyRetain VolumeRetentionPolicy = "Retain"
+
+	// VolumeRetentionPolicyDelete deletes the persistent volume claims.
+	VolumeRetentionPolicyDelete VolumeRetentionPolicy = "Delete"
+)
+
+// StorageSpec defines the persistent storage of a Workload
+type StorageSpec struct {
+	// Volumes are the persistent volumes of every pod. Only the size of a
+	// volume can be changed, and only increased; existing claims are
+	// expanded when their storage class allows it.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MinItems=1
+	Volumes []StorageVolume `json:"volumes"`
+
+	// WhenDeleted is the retention policy of the claims when the Workload is
+	// deleted, Retain or Delete. Defaults to Retain.
+	// +kubebuilder:default=Retain
+	// +optional
+	WhenDeleted VolumeRetentionPolicy `json:"whenDeleted,omitempty"`
+
+	// WhenScaled is the retention policy of the claims of the pods removed
+	// when the Workload is scaled down, Retain or Delete. Defaults to Retain.
+	// +kubebuilder:default=Retain
+	// +optional
+	WhenScaled VolumeRetentionPolicy `json:"whenScaled,omitempty"`
+}
+
+// StorageVolume defines a persistent volume of the pods of a Workload
+type StorageVolume struct {
+	// Name of the volume. This must be a DNS_LABEL.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// MountPath is the directory the volume is mounted at in the container.
+	// +kubebuilder:validation:MinLength=1
+	MountPath string `json:"mountPath"`
+
+	// Size of the volume.
+	Size resource.Quantity `json:"size"`
+
+	// StorageClassName of the claims. The cluster default storage class is
+	// used when this is not provided.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+
+	// AccessMode of the claims.
+	// Defaults to ReadWriteOnce.
+	// +kubebuilder:validation:Enum=ReadWriteOnce;ReadOnlyMany;ReadWriteMany;ReadWriteOncePod
+	// +kubebuilder:default=ReadWriteOnce
+	// +optional
+	AccessMode corev1.PersistentVolumeAccessMode `json:"accessMode,omitempty"`
+}
+
+// ServiceType describes how a Workload Service is exposed.
+// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer;Headless
+type ServiceType string
+
+const (
+	// ServiceTypeClusterIP exposes the workload on a cluster-internal IP.
+	ServiceTypeClusterIP ServiceType = "ClusterIP"
+
+	// ServiceTypeNodePort exposes the workload on a port of every node.
+	ServiceTypeNodePort ServiceType = "NodePort"
+
+	// ServiceTypeLoadBalancer exposes the workload through an external load balancer.
+	ServiceTypeLoadBalancer ServiceType = "LoadBalancer"
+
+	// ServiceTypeHeadless exposes the workload through DNS records of its pods,
+	// without allocating a cluster IP.
+	ServiceTypeHeadless ServiceType = "Headless"
+)
+
+// ServiceSpec defines the Service that exposes a Workload
+type ServiceSpec struct {
+	// Type determines how the Service is exposed.
+	// Defaults to ClusterIP.
+	// +kubebuilder:default=ClusterIP
+	// +optional
+	Type ServiceType `json:"type,omitempty"`
+
+	// The list of ports that are exposed by this service.
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MinItems=1
+	Ports []ServicePort `json:"ports"`
+}
+
+// ServicePort defines a named port exposed by the Service of a Workload
+type ServicePort struct {
+	// The name of this port within the service. This must be a DNS_LABEL.
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+
+	// The IP protocol for this port. Supports "TCP", "UDP", and "SCTP".
+	// Defaults to TCP.
+	// +kubebuilder:default=TCP
+	// +optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+
+	// The port that will be exposed by this service.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// Number or name of the container port to access on the pods.
+	// Defaults to the value of the 'port' field.
+	// +optional
+	TargetPort intstr.IntOrString `json:"targetPort,omitempty"`
+
+	// The port on each node on which this service is exposed when type is
+	// NodePort or LoadBalancer. Allocated by the system when not provided.
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+}
+
+// NetworkSpec defines the allowed traffic of a Workload
+type NetworkSpec struct {
+	// AllowFrom lists the peers that may connect to the pods of this workload.
+	// +optional
+	AllowFrom []NetworkPeer `json:"allowFrom,omitempty"`
+
+	// AllowTo lists the peers the pods of this workload may connect to.
+	// +optional
+	AllowTo []NetworkPeer `json:"allowTo,omitempty"`
+}
+
+// NetworkPeer defines the other end of the allowed traffic of a Workload
+// +kubebuilder:validation:XValidation:rule="has(self.workload) || has(self.namespace)",message="workload or namespace must be set"
+type NetworkPeer struct {
+	// Workload is the name of the Workload whose pods are the peer.
+	// +optional
+	Workload string `json:"workload,omitempty"`
+
+	// Namespace is the namespace of the peer. When workload is set, it
+	// defaults to the namespace of this workload; otherwise all pods of the
+	// namespace are the peer.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+
+	// Ports restricts the traffic to the given ports. These are ports of this
+	// workload for allowFrom and ports of the peer for allowTo.
+	// All ports are allowed when this is not provided.
+	// +optional
+	Ports []NetworkPort `json:"ports,omitempty"`
+}
+
+// NetworkPort defines a port of allowed traffic
+type NetworkPort struct {
+	// The IP protocol of the traffic. Supports "TCP", "UDP", and "SCTP".
+	// Defaults to TCP.
+	// +kubebuilder:default=TCP
+	// +optional
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+
+	// Number or name of the port.
+	Port intstr.IntOrString `json:"port"`
+}
+
+// IngressSpec defines the external routing of a Workload
+type IngressSpec struct {
+	// Hosts are the fully qualified domain names the workload is reachable on.
+	// +kubebuilder:validation:MinItems=1
+	Hosts []string `json:"hosts"`
+
+	// Paths routed to the workload.
+	// Defaults to all paths routed to the first Service port.
+	// +optional
+	Paths []IngressPath `json:"paths,omitempty"`
+
+	// TLS enables TLS termination for the hosts.
+	// +optional
+	TLS *IngressTLS `json:"tls,omitempty"`
+}
+
+// PathType determines how the path of a request is matched.
+// +kubebuilder:validation:Enum=Prefix;Exact
+type PathType string
+
+const (
+	// PathTypePrefix matches based on a URL path prefix split by '/'.
+	PathTypePrefix PathType = "Prefix"
+
+	// PathTypeExact matches the URL path exactly.
+	PathTypeExact PathType = "Exact"
+)
+
+// IngressPath defines a path routed to the Service of a Workload
+type IngressPath struct {
+	// Path is matched against the path of an incoming request.
+	// +kubebuilder:validation:Pattern=`^/`
+	Path string `json:"path"`
+
+	// PathType determines how the path is matched.
+	// Defaults to Prefix.
+	// +kubebuilder:default=Prefix
+	// +optional
+	PathType PathType `json:"pathType,omitempty"`
+
+	// Port is the name of the Service port receiving the traffic.
+	// Defaults to the first Service port.
+	// +optional
+	Port string `json:"port,omitempty"`
+}
+
+// IngressTLS defines the TLS termination of a Workload's hosts
+type IngressTLS struct {
+	// SecretName is the name of the Secret holding the TLS certificate and key
+	// for the hosts. Only used by Ingress objects; with Gateway API, TLS is
+	// terminated by the Gateway listener configured in the operator.
+	// +optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// IdentityStatus describes the cloud identity of a Workload
+type IdentityStatus struct {
+	// Principal is the resolved cloud principal, e.g. the ARN of the IAM role.
+	Principal string `json:"principal"`
+
+	// Issuer is the OIDC issuer of the ServiceAccount tokens of the cluster.
+	// +optional
+	Issuer string `json:"issuer,omitempty"`
+
+	// Subject is the subject of the ServiceAccount tokens.
+	Subject string `json:"subject"`
+}
+
+// DeploymentColor is one of the two Deployments of a blue/green Workload
+type DeploymentColor string
+
+const (
+	// DeploymentColorBlue is the Deployment <name>-blue.
+	DeploymentColorBlue DeploymentColor = "blue"
+
+	// DeploymentColorGreen is the Deployment <name>-green.
+	DeploymentColorGreen DeploymentColor = "green"
+)
+
+// BlueGreenStatus describes the blue/green rollout of a Workload
+type BlueGreenStatus struct {
+	// ActiveColor is the color selected by the Service of the Workload.
+	ActiveColor DeploymentColor `json:"activeColor"`
+
+	// PreviewColor is the color running the latest spec while it awaits
+	// promotion.
+	// +optional
+	PreviewColor DeploymentColor `json:"previewColor,omitempty"`
+
+	// Message describes the state of the preview.
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// RollbackHandled is set once the rollback annotation of the Workload was
+	// acted upon, until the annotation is removed.
+	// +optional
+	RollbackHandled bool `json:"rollbackHandled,omitempty"`
+}
+
+// CanaryPhase is the state of a canary rollout
+type CanaryPhase string
+
+const (
+	// CanaryPhaseProgressing is set while traffic is shifted to the canary.
+	CanaryPhaseProgressing CanaryPhase = "Progressing"
+
+	// CanaryPhasePromoted is set once the canary image is rolled out to all
+	// replicas of the Workload.
+	CanaryPhasePromoted CanaryPhase = "Promoted"
+
+	// CanaryPhaseAborted is set when the canary failed its readiness or its
+	// analysis. The Workload keeps running the stable image until the image
+	// is changed again.
+	CanaryPhaseAborted CanaryPhase = "Aborted"
+)
+
+// CanaryStatus describes the canary rollout of a Workload
+type CanaryStatus struct {
+	// Phase is one of Progressing, Promoted or Aborted.
+	Phase CanaryPhase `json:"phase"`
+
+	// Image is the image rolled out by the canary.
+	Image string `json:"image"`
+
+	// StableImage is the image of the Workload before the canary.
+	StableImage string `json:"stableImage"`
+
+	// Step is the index of the current step of the canary.
+	Step int32 `json:"step"`
+
+	// Weight is the percentage of traffic currently sent to the canary.
+	// +optional
+	Weight int32 `json:"weight,omitempty"`
+
+	// StepStartTime is the time the weight of the current step was applied.
+	// +optional
+	StepStartTime *metav1.Time `json:"stepStartTime,omitempty"`
+
+	// ErrorRate is the last result of the analysis of the canary. It is
+	// empty when the last analysis was inconclusive.
+	// +optional
+	ErrorRate string `json:"errorRate,omitempty"`
+
+	// LastAnalysisTime is the time the analysis of the canary was last run.
+	// +optional
+	LastAnalysisTime *metav1.Time `json:"lastAnalysisTime,omitempty"`
+
+	// Message describes the progress or the failure of the canary.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// WorkloadPhase is a summary of the conditions of a Workload
+type WorkloadPhase string
+
+const (
+	// WorkloadPhasePending is set before the Workload was reconciled.
+	WorkloadPhasePending WorkloadPhase = "Pending"
+
+	// WorkloadPhaseProgressing is set while the objects of the Workload roll out.
+	WorkloadPhaseProgressing WorkloadPhase = "Progressing"
+
+	// WorkloadPhaseReady is set when all objects of the Workload are rolled out.
+	WorkloadPhaseReady WorkloadPhase = "Ready"
+
+	// WorkloadPhaseDegraded is set when the Workload can not be reconciled.
+	WorkloadPhaseDegraded WorkloadPhase = "Degraded"
+
+	// WorkloadPhaseTerminating is set while the Workload is deleted.
+	WorkloadPhaseTerminating WorkloadPhase = "Terminating"
+)
+
 // WorkloadStatus defines the observed state of Workload
 type WorkloadStatus struct {
 	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
+	// ObservedGeneration is the most recent generation of the Workload spec
+	// that was reconciled. The conditions are only current when it equals
+	// metadata.generation.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Phase summarizes the conditions of the Workload. One of Pending,
+	// Progressing, Ready, Degraded or Terminating.
+	// +optional
+	Phase WorkloadPhase `json:"phase,omitempty"`
+
+	// Summary of the ready and desired replicas, e.g. 2/3. For Job workloads,
+	// of the succeeded and desired completions; for CronJob workloads, of
+	// the active runs.
+	// +optional
+	Summary string `json:"summary,omitempty"`
+
+	// LastReconcileTime is the last time a reconciliation changed the status
+	// of the Workload.
+	// +optional
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+
+	// CurrentRevision is the name of the last WorkloadRevision that was
+	// rolled out successfully.
+	// +optional
+	CurrentRevision string `json:"currentRevision,omitempty"`
+
+	// UpdateRevision is the name of the WorkloadRevision of the current spec.
+	// +optional
+	UpdateRevision string `json:"updateRevision,omitempty"`
+
 	// Pointer to ServiceAccount object.
 	// +optional
 	ServiceAccount corev1.ObjectReference `json:"serviceAccount,omitempty"`
 
-	// Conditions represent the latest available observations of an object's state
-	Conditions []metav1.Condition `json:"conditions"`
+	// Identity is the cloud identity of the ServiceAccount, with the values
+	// needed to set up its trust relationship.
+	// +optional
+	Identity *IdentityStatus `json:"identity,omitempty"`
+
+	// Pointer to Deployment object.
+	// +optional
+	Deployment corev1.ObjectReference `json:"deployment,omitempty"`
+
+	// Canary is the state of the last canary rollout of the Workload.
+	// +optional
+	Canary *CanaryStatus `json:"canary,omitempty"`
+
+	// BlueGreen is the state of the blue/green rollout of the Workload.
+	// +optional
+	BlueGreen *BlueGreenStatus `json:"blueGreen,omitempty"`
+
+	// Pointer to StatefulSet object.
+	// +optional
+	StatefulSet corev1.ObjectReference `json:"statefulSet,omitempty"`
+
+	// Pointer to the Job or CronJob object.
+	// +optional
+	Job corev1.ObjectReference `json:"job,omitempty"`
+
+	// LastRunTime is the time the last run of a Job or CronJob workload started.
+	// +optional
+	LastRunTime *metav1.Time `json:"lastRunTime,omitempty"`
+
+	// LastSuccessTime is the time the last run of a Job or CronJob workload
+	// completed successfully.
+	// +optional
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+
+	// Pointer to Service object.
+	// +optional
+	Service corev1.ObjectReference `json:"service,omitempty"`
+
+	// Pointer to the Ingress or HTTPRoute object.
+	// +optional
+	Route corev1.ObjectReference `json:"route,omitempty"`
+
+	// Inventory lists every object applied for this workload. Objects that
+	// are no longer desired are pruned using this list.
+	// +optional
+	Inventory []corev1.ObjectReference `json:"inventory,omitempty"`
+
+	// Number of pods the Deployment or StatefulSet asks for.
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas,omitempty"`
+
+	// Total number of non-terminated pods targeted by the Deployment.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Number of pods targeted by the Deployment with a Ready Condition.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Number of pods targeted by the Deployment that have been available
+	// for at least minReadySeconds.
+	// +optional
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+
+	// Conditions represent the latest available observations of an object's state.
+	// Ready, Progressing and Degraded summarize the whole Workload, Available
+	// reflects the Deployment, and a <Kind>Ready condition (e.g. ServiceReady)
+	// is kept for every kind of object applied for the Workload.
+	// +listType=map
+	// +listMapKey=type
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	Conditions []metav1.Condition `json:"conditions" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:resource:shortName=wl,categories=platform
+//+kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+//+kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.summary`
+//+kubebuilder:printcolumn:name="Service Account",type=string,JSONPath=`.status.serviceAccount.name`
+//+kubebuilder:printcolumn:name="Last Reconcile",type=date,JSONPath=`.status.lastReconcileTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Workload is the Schema for the workloads API
 type Workload struct {
@@ -0,0 +1,401 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// DefaultCPURequest is the CPU requested by containers without resources
+	DefaultCPURequest = "100m"
+	// DefaultMemoryRequest is the memory requested by containers without resources
+	DefaultMemoryRequest = "128Mi"
+
+	// nameLabel is defaulted on Workloads to their name
+	nameLabel = "app.kubernetes.io/name"
+
+	// maxNameLength leaves room for the longest suffix appended to the names
+	// of child objects, the "-<hash>" of Jobs and WorkloadRevisions (up to 11
+	// characters, longer than "-headless", "-preview" and "-canary"), so that
+	// the child names are valid DNS-1035 labels as well.
+	maxNameLength = validation.DNS1035LabelMaxLength - 11
+)
+
+// log is for logging in this package.
+var workloadlog = logf.Log.WithName("workload-resource")
+
+// SetupWebhookWithManager will setup the manager to manage the webhooks
+func (r *Workload) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(r).
+		Complete()
+}
+
+//+kubebuilder:webhook:path=/mutate-platform-mydev-org-v1-workload,mutating=true,failurePolicy=fail,sideEffects=None,groups=platform.mydev.org,resources=workloads,verbs=create;update,versions=v1,name=mworkload.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Defaulter = &Workload{}
+
+// Default implements webhook.Defaulter so a webhook will be registered for the type
+func (r *Workload) Default() {
+	workloadlog.Info("default", "name", r.Name)
+
+	// names are not known yet for objects created with generateName
+	if r.Name != "" {
+		if r.Spec.ServiceAccountName == "" {
+			r.Spec.ServiceAccountName = r.Name
+		}
+
+		if _, ok := r.Labels[nameLabel]; !ok {
+			if r.Labels == nil {
+				r.Labels = map[string]string{}
+			}
+			r.Labels[nameLabel] = r.Name
+		}
+	}
+
+	if r.Spec.Kind == "" {
+		r.Spec.Kind = WorkloadKindDeployment
+	}
+
+	if r.Spec.DeletionPolicy == "" {
+		r.Spec.DeletionPolicy = DeletionPolicyDelete
+	}
+
+	if r.Spec.Availability == "" {
+		r.Spec.Availability = AvailabilityClassStandard
+	}
+
+	if r.Spec.DriftPolicy == "" {
+		r.Spec.DriftPolicy = DriftPolicyCorrect
+	}
+
+	if r.Spec.Container == nil {
+		return
+	}
+
+	resources := &r.Spec.Container.Resources
+	if len(resources.Requests) == 0 && len(resources.Limits) == 0 {
+		resources.Requests = corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(DefaultCPURequest),
+			corev1.ResourceMemory: resource.MustParse(DefaultMemoryRequest),
+		}
+	}
+}
+
+//+kubebuilder:webhook:path=/validate-platform-mydev-org-v1-workload,mutating=false,failurePolicy=fail,sideEffects=None,groups=platform.mydev.org,resources=workloads,verbs=create;update,versions=v1,name=vworkload.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &Workload{}
+
+// ValidateCreate implements webhook.Validator so a webhook will be registered for the type
+func (r *Workload) ValidateCreate() (admission.Warnings, error) {
+	workloadlog.Info("validate create", "name", r.Name)
+
+	return nil, r.toInvalidError(r.validateWorkload())
+}
+
+// ValidateUpdate implements webhook.Validator so a webhook will be registered for the type
+func (r *Workload) ValidateUpdate(old runtime.Object) (admission.Warnings, error) {
+	workloadlog.Info("validate update", "name", r.Name)
+
+	oldWorkload, ok := old.(*Workload)
+	if !ok {
+		return nil, fmt.Errorf("expected a Workload but got a %T", old)
+	}
+
+	// never block the removal of finalizers from a deleted workload
+	if !r.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+
+	allErrs := r.validateWorkload()
+	allErrs = append(allErrs, r.validateImmutableFields(oldWorkload)...)
+
+	return nil, r.toInvalidError(allErrs)
+}
+
+// ValidateDelete implements webhook.Validator so a webhook will be registered for the type
+func (r *Workload) ValidateDelete() (admission.Warnings, error) {
+	workloadlog.Info("validate delete", "name", r.Name)
+
+	return nil, nil
+}
+
+func (r *Workload) toInvalidError(allErrs field.ErrorList) error {
+	if len(allErrs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(schema.GroupKind{Group: GroupVersion.Group, Kind: "Workload"}, r.Name, allErrs)
+}
+
+func (r *Workload) validateWorkload() field.ErrorList {
+	var allErrs field.ErrorList
+
+	// the workload name is used for its Service, so it must be a DNS-1035 label
+	for _, msg := range validation.IsDNS1035Label(r.Name) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("name"), r.Name, msg))
+	}
+	if len(r.Name) > maxNameLength {
+		allErrs = append(allErrs, field.TooLong(field.NewPath("metadata").Child("name"), r.Name, maxNameLength))
+	}
+	// the RoleBindings of ClusterRoles are named <name>-clusterrole-<clusterrole>
+	if strings.Contains(r.Name, "-clusterrole-") {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("metadata").Child("name"), r.Name,
+			`must not contain "-clusterrole-", it separates the names of ClusterRole bindings`))
+	}
+
+	specPath := field.NewPath("spec")
+	allErrs = append(allErrs, r.validateServiceAccountName(specPath.Child("serviceAccountName"))...)
+	allErrs = append(allErrs, r.validateContainer(specPath.Child("container"))...)
+	allErrs = append(allErrs, r.validateConfig(specPath.Child("config"))...)
+	allErrs = append(allErrs, r.validateService(specPath.Child("service"))...)
+	allErrs = append(allErrs, r.validateIngress(specPath.Child("ingress"))...)
+	allErrs = append(allErrs, r.validateNetwork(specPath.Child("network"))...)
+
+	return allErrs
+}
+
+func (r *Workload) validateServiceAccountName(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	name := r.Spec.ServiceAccountName
+	if name == "" {
+		return allErrs
+	}
+
+	for _, msg := range validation.IsDNS1123Subdomain(name) {
+		allErrs = append(allErrs, field.Invalid(fldPath, name, msg))
+	}
+
+	// the operator owns the service account, so it must not take over the namespace default
+	if name == "default" {
+		allErrs = append(allErrs, field.Forbidden(fldPath, "the default service account of the namespace can not be used"))
+	}
+
+	return allErrs
+}
+
+func (r *Workload) validateContainer(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if r.Spec.Container == nil {
+		return allErrs
+	}
+
+	for i, port := range r.Spec.Container.Ports {
+		if port.HostPort != 0 {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("ports").Index(i).Child("hostPort"), "host ports are not allowed"))
+		}
+	}
+
+	return allErrs
+}
+
+func (r *Workload) validateConfig(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if r.Spec.Config == nil {
+		return allErrs
+	}
+
+	names := make([]string, 0, len(r.Spec.Config.Env))
+	for name := range r.Spec.Config.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, msg := range validation.IsEnvVarName(name) {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("env").Key(name), name, msg))
+		}
+	}
+
+	// env entries and files share the keys of the ConfigMap
+	for i, file := range r.Spec.Config.Files {
+		filePath := fldPath.Child("files").Index(i).Child("name")
+		for _, msg := range validation.IsConfigMapKey(file.Name) {
+			allErrs = append(allErrs, field.Invalid(filePath, file.Name, msg))
+		}
+		if _, ok := r.Spec.Config.Env[file.Name]; ok {
+			allErrs = append(allErrs, field.Duplicate(filePath, file.Name))
+		}
+	}
+
+	return allErrs
+}
+
+func (r *Workload) validateService(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if r.Spec.Service == nil {
+		return allErrs
+	}
+
+	containerPorts := map[string]bool{}
+	if r.Spec.Container != nil {
+		for _, port := range r.Spec.Container.Ports {
+			if port.Name != "" {
+				containerPorts[port.Name] = true
+			}
+		}
+	}
+
+	for i, port := range r.Spec.Service.Ports {
+		portPath := fldPath.Child("ports").Index(i)
+
+		if port.TargetPort.StrVal != "" && !containerPorts[port.TargetPort.StrVal] {
+			allErrs = append(allErrs, field.NotFound(portPath.Child("targetPort"), port.TargetPort.StrVal))
+		}
+
+		if port.NodePort != 0 && r.Spec.Service.Type != ServiceTypeNodePort && r.Spec.Service.Type != ServiceTypeLoadBalancer {
+			allErrs = append(allErrs, field.Forbidden(portPath.Child("nodePort"), "may only be set for NodePort and LoadBalancer services"))
+		}
+	}
+
+	return allErrs
+}
+
+func (r *Workload) validateIngress(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if r.Spec.Ingress == nil {
+		return allErrs
+	}
+
+	for i, host := range r.Spec.Ingress.Hosts {
+		hostPath := fldPath.Child("hosts").Index(i)
+		if len(host) > 0 && host[0] == '*' {
+			allErrs = append(allErrs, field.Forbidden(hostPath, "wildcard hosts are not allowed"))
+			continue
+		}
+		for _, msg := range validation.IsDNS1123Subdomain(host) {
+			allErrs = append(allErrs, field.Invalid(hostPath, host, msg))
+		}
+	}
+
+	servicePorts := map[string]bool{}
+	if r.Spec.Service != nil {
+		for _, port := range r.Spec.Service.Ports {
+			servicePorts[port.Name] = true
+		}
+	}
+
+	for i, path := range r.Spec.Ingress.Paths {
+		if path.Port != "" && !servicePorts[path.Port] {
+			allErrs = append(allErrs, field.NotFound(fldPath.Child("paths").Index(i).Child("port"), path.Port))
+		}
+	}
+
+	return allErrs
+}
+
+func (r *Workload) validateNetwork(fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if r.Spec.Network == nil {
+		return allErrs
+	}
+
+	directions := []struct {
+		name  string
+		peers []NetworkPeer
+	}{
+		{"allowFrom", r.Spec.Network.AllowFrom},
+		{"allowTo", r.Spec.Network.AllowTo},
+	}
+	for _, direction := range directions {
+		for i, peer := range direction.peers {
+			peerPath := fldPath.Child(direction.name).Index(i)
+			if peer.Workload != "" {
+				for _, msg := range validation.IsDNS1035Label(peer.Workload) {
+					allErrs = append(allErrs, field.Invalid(peerPath.Child("workload"), peer.Workload, msg))
+				}
+			}
+			if peer.Namespace != "" {
+				for _, msg := range validation.IsDNS1123Label(peer.Namespace) {
+					allErrs = append(allErrs, field.Invalid(peerPath.Child("namespace"), peer.Namespace, msg))
+				}
+			}
+			if peer.Workload == "" && peer.Namespace == "" {
+				allErrs = append(allErrs, field.Required(peerPath, "workload or namespace must be set"))
+			}
+		}
+	}
+
+	return allErrs
+}
+
+func (r *Workload) validateImmutableFields(old *Workload) field.ErrorList {
+	var allErrs field.ErrorList
+
+	// the cluster IP of a Service can not be added or removed once allocated
+	if r.Spec.Service != nil && old.Spec.Service != nil &&
+		(r.Spec.Service.Type == ServiceTypeHeadless) != (old.Spec.Service.Type == ServiceTypeHeadless) {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec").Child("service").Child("type"),
+			r.Spec.Service.Type, "can not be changed from or to Headless"))
+	}
+
+	// the volume claim templates of a StatefulSet can not be changed, only
+	// existing claims can be expanded
+	if r.Spec.Storage != nil && old.Spec.Storage != nil {
+		volumesPath := field.NewPath("spec").Child("storage").Child("volumes")
+		oldVolumes := map[string]StorageVolume{}
+		for _, volume := range old.Spec.Storage.Volumes {
+			oldVolumes[volume.Name] = volume
+		}
+
+		if len(r.Spec.Storage.Volumes) != len(old.Spec.Storage.Volumes) {
+			allErrs = append(allErrs, field.Forbidden(volumesPath, "volumes can not be added or removed"))
+		}
+
+		for i, volume := range r.Spec.Storage.Volumes {
+			volumePath := volumesPath.Index(i)
+			oldVolume, ok := oldVolumes[volume.Name]
+			if !ok {
+				allErrs = append(allErrs, field.Forbidden(volumePath.Child("name"), "volumes can not be added or removed"))
+				continue
+			}
+
+			if volume.Size.Cmp(oldVolume.Size) < 0 {
+				allErrs = append(allErrs, field.Forbidden(volumePath.Child("size"), "volumes can not be shrunk"))
+			}
+			if !equality.Semantic.DeepEqual(volume.StorageClassName, oldVolume.StorageClassName) {
+				allErrs = append(allErrs, field.Forbidden(volumePath.Child("storageClassName"), "field is immutable"))
+			}
+			if volume.AccessMode != oldVolume.AccessMode {
+				allErrs = append(allErrs, field.Forbidden(volumePath.Child("accessMode"), "field is immutable"))
+			}
+		}
+	}
+
+	return allErrs
+}
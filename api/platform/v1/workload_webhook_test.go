@@ -0,0 +1,339 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"k8s.io/utils/pointer"
+)
+
+func TestValidateWorkloadName(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{name: "api"},
+		{name: "api-view"},
+		{name: "api-clusterrole-view", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := &Workload{ObjectMeta: metav1.ObjectMeta{Name: tt.name}}
+			if errs := workload.validateWorkload(); (len(errs) != 0) != tt.wantErr {
+				t.Errorf("validateWorkload() = %v, wantErr %v", errs, tt.wantErr)
+			}
+		})
+	}
+}
+
+// validWorkload returns the workload "web" with a container, Service and Ingress
+// that pass validation.
+func validWorkload() *Workload {
+	return &Workload{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec: WorkloadSpec{
+			Container: &ContainerSpec{
+				Image: "app:v1",
+				Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+			},
+			Service: &ServiceSpec{
+				Type:  ServiceTypeClusterIP,
+				Ports: []ServicePort{{Name: "http", Port: 80, TargetPort: intstr.FromString("http")}},
+			},
+			Ingress: &IngressSpec{
+				Hosts: []string{"example.com"},
+				Paths: []IngressPath{{Path: "/", Port: "http"}},
+			},
+		},
+	}
+}
+
+// errorFields returns the fields of the errors.
+func errorFields(errs field.ErrorList) []string {
+	var fields []string
+	for _, err := range errs {
+		fields = append(fields, err.Field)
+	}
+
+	return fields
+}
+
+func TestDefault(t *testing.T) {
+	workload := validWorkload()
+	workload.Default()
+
+	if workload.Spec.ServiceAccountName != "web" || workload.Labels[nameLabel] != "web" {
+		t.Errorf("expected the ServiceAccount and the name label to default to web, got %q and %v",
+			workload.Spec.ServiceAccountName, workload.Labels)
+	}
+	if workload.Spec.Kind != WorkloadKindDeployment || workload.Spec.DeletionPolicy != DeletionPolicyDelete ||
+		workload.Spec.Availability != AvailabilityClassStandard || workload.Spec.DriftPolicy != DriftPolicyCorrect {
+		t.Errorf("expected the policies to be defaulted, got %v", workload.Spec)
+	}
+	requests := workload.Spec.Container.Resources.Requests
+	if requests.Cpu().String() != DefaultCPURequest || requests.Memory().String() != DefaultMemoryRequest {
+		t.Errorf("expected the default resource requests, got %v", requests)
+	}
+
+	// set fields are kept
+	workload = validWorkload()
+	workload.Labels = map[string]string{nameLabel: "frontend"}
+	workload.Spec.ServiceAccountName = "shared"
+	workload.Spec.DriftPolicy = DriftPolicyReport
+	workload.Spec.Container.Resources.Limits = corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")}
+	workload.Default()
+	if workload.Spec.ServiceAccountName != "shared" || workload.Labels[nameLabel] != "frontend" ||
+		workload.Spec.DriftPolicy != DriftPolicyReport || len(workload.Spec.Container.Resources.Requests) != 0 {
+		t.Errorf("expected the set fields to be kept, got %v", workload.Spec)
+	}
+
+	// the name of a workload created with generateName is not known yet
+	workload = validWorkload()
+	workload.Name = ""
+	workload.GenerateName = "web-"
+	workload.Spec.Container = nil
+	workload.Default()
+	if workload.Spec.ServiceAccountName != "" || workload.Labels != nil {
+		t.Errorf("expected no defaults from the name, got %q and %v", workload.Spec.ServiceAccountName, workload.Labels)
+	}
+}
+
+func TestValidateWorkload(t *testing.T) {
+	tests := []struct {
+		name       string
+		change     func(*Workload)
+		wantFields []string
+	}{
+		{name: "valid"},
+		{
+			name:       "name of a Service",
+			change:     func(w *Workload) { w.Name = "1web" },
+			wantFields: []string{"metadata.name"},
+		},
+		{
+			name:   "longest name",
+			change: func(w *Workload) { w.Name = strings.Repeat("w", maxNameLength) },
+		},
+		{
+			name:       "name without room for the suffixes",
+			change:     func(w *Workload) { w.Name = strings.Repeat("w", maxNameLength+1) },
+			wantFields: []string{"metadata.name"},
+		},
+		{
+			name:       "default ServiceAccount",
+			change:     func(w *Workload) { w.Spec.ServiceAccountName = "default" },
+			wantFields: []string{"spec.serviceAccountName"},
+		},
+		{
+			name:       "host port",
+			change:     func(w *Workload) { w.Spec.Container.Ports[0].HostPort = 80 },
+			wantFields: []string{"spec.container.ports[0].hostPort"},
+		},
+		{
+			name: "config keys",
+			change: func(w *Workload) {
+				w.Spec.Config = &ConfigSpec{
+					Env:   map[string]string{"MODE": "prod", "1MODE": "prod"},
+					Files: []ConfigFile{{Name: "MODE"}, {Name: "app/config.yaml"}},
+				}
+			},
+			wantFields: []string{"spec.config.env[1MODE]", "spec.config.files[0].name", "spec.config.files[1].name"},
+		},
+		{
+			name:       "unknown target port",
+			change:     func(w *Workload) { w.Spec.Service.Ports[0].TargetPort = intstr.FromString("grpc") },
+			wantFields: []string{"spec.service.ports[0].targetPort"},
+		},
+		{
+			name:       "node port of a ClusterIP Service",
+			change:     func(w *Workload) { w.Spec.Service.Ports[0].NodePort = 30080 },
+			wantFields: []string{"spec.service.ports[0].nodePort"},
+		},
+		{
+			name: "node port of a NodePort Service",
+			change: func(w *Workload) {
+				w.Spec.Service.Type = ServiceTypeNodePort
+				w.Spec.Service.Ports[0].NodePort = 30080
+			},
+		},
+		{
+			name:       "hosts",
+			change:     func(w *Workload) { w.Spec.Ingress.Hosts = []string{"*.example.com", "Example.com"} },
+			wantFields: []string{"spec.ingress.hosts[0]", "spec.ingress.hosts[1]"},
+		},
+		{
+			name:       "unknown path port",
+			change:     func(w *Workload) { w.Spec.Ingress.Paths[0].Port = "grpc" },
+			wantFields: []string{"spec.ingress.paths[0].port"},
+		},
+		{
+			name: "network peers",
+			change: func(w *Workload) {
+				w.Spec.Network = &NetworkSpec{
+					AllowFrom: []NetworkPeer{{Workload: "frontend"}, {}},
+					AllowTo:   []NetworkPeer{{Workload: "DB", Namespace: "data_"}, {Namespace: "monitoring"}},
+				}
+			},
+			wantFields: []string{"spec.network.allowFrom[1]", "spec.network.allowTo[0].workload", "spec.network.allowTo[0].namespace"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := validWorkload()
+			if tt.change != nil {
+				tt.change(workload)
+			}
+
+			got := errorFields(workload.validateWorkload())
+			if !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("validateWorkload() errors of %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestValidateImmutableFields(t *testing.T) {
+	storage := func(size, class string) *StorageSpec {
+		return &StorageSpec{Volumes: []StorageVolume{
+			{Name: "data", MountPath: "/data", Size: resource.MustParse(size), StorageClassName: pointer.String(class)},
+		}}
+	}
+
+	tests := []struct {
+		name       string
+		old        func(*Workload)
+		change     func(*Workload)
+		wantFields []string
+	}{
+		{name: "unchanged"},
+		{
+			name:       "to headless",
+			change:     func(w *Workload) { w.Spec.Service.Type = ServiceTypeHeadless },
+			wantFields: []string{"spec.service.type"},
+		},
+		{
+			name:       "from headless",
+			old:        func(w *Workload) { w.Spec.Service.Type = ServiceTypeHeadless },
+			wantFields: []string{"spec.service.type"},
+		},
+		{
+			name:   "between types with a cluster IP",
+			change: func(w *Workload) { w.Spec.Service.Type = ServiceTypeLoadBalancer },
+		},
+		{
+			name:   "added Service",
+			old:    func(w *Workload) { w.Spec.Service = nil },
+			change: func(w *Workload) { w.Spec.Service.Type = ServiceTypeHeadless },
+		},
+		{
+			name:   "expanded volume",
+			old:    func(w *Workload) { w.Spec.Storage = storage("1Gi", "standard") },
+			change: func(w *Workload) { w.Spec.Storage = storage("2Gi", "standard") },
+		},
+		{
+			name:       "shrunk volume",
+			old:        func(w *Workload) { w.Spec.Storage = storage("2Gi", "standard") },
+			change:     func(w *Workload) { w.Spec.Storage = storage("1Gi", "standard") },
+			wantFields: []string{"spec.storage.volumes[0].size"},
+		},
+		{
+			name:       "storage class",
+			old:        func(w *Workload) { w.Spec.Storage = storage("1Gi", "standard") },
+			change:     func(w *Workload) { w.Spec.Storage = storage("1Gi", "fast") },
+			wantFields: []string{"spec.storage.volumes[0].storageClassName"},
+		},
+		{
+			name: "added volume",
+			old:  func(w *Workload) { w.Spec.Storage = storage("1Gi", "standard") },
+			change: func(w *Workload) {
+				w.Spec.Storage = storage("1Gi", "standard")
+				w.Spec.Storage.Volumes = append(w.Spec.Storage.Volumes, StorageVolume{Name: "logs", MountPath: "/logs"})
+			},
+			wantFields: []string{"spec.storage.volumes", "spec.storage.volumes[1].name"},
+		},
+		{
+			name: "renamed volume",
+			old:  func(w *Workload) { w.Spec.Storage = storage("1Gi", "standard") },
+			change: func(w *Workload) {
+				w.Spec.Storage = storage("1Gi", "standard")
+				w.Spec.Storage.Volumes[0].Name = "cache"
+			},
+			wantFields: []string{"spec.storage.volumes[0].name"},
+		},
+		{
+			name: "removed volume",
+			old: func(w *Workload) {
+				w.Spec.Storage = storage("1Gi", "standard")
+				w.Spec.Storage.Volumes = append(w.Spec.Storage.Volumes, StorageVolume{Name: "logs", MountPath: "/logs"})
+			},
+			change:     func(w *Workload) { w.Spec.Storage = storage("1Gi", "standard") },
+			wantFields: []string{"spec.storage.volumes"},
+		},
+		{
+			name: "access mode",
+			old:  func(w *Workload) { w.Spec.Storage = storage("1Gi", "standard") },
+			change: func(w *Workload) {
+				w.Spec.Storage = storage("1Gi", "standard")
+				w.Spec.Storage.Volumes[0].AccessMode = corev1.ReadWriteMany
+			},
+			wantFields: []string{"spec.storage.volumes[0].accessMode"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, workload := validWorkload(), validWorkload()
+			if tt.old != nil {
+				tt.old(old)
+			}
+			if tt.change != nil {
+				tt.change(workload)
+			}
+
+			got := errorFields(workload.validateImmutableFields(old))
+			if !reflect.DeepEqual(got, tt.wantFields) {
+				t.Errorf("validateImmutableFields() errors of %v, want %v", got, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestValidateUpdateOfDeletedWorkload(t *testing.T) {
+	old := validWorkload()
+	old.Spec.Service.Type = ServiceTypeHeadless
+	workload := validWorkload()
+	now := metav1.Now()
+	workload.DeletionTimestamp = &now
+
+	if _, err := workload.ValidateUpdate(old); err != nil {
+		t.Errorf("expected the update of a deleted workload to be allowed, got %v", err)
+	}
+	workload.DeletionTimestamp = nil
+	if _, err := workload.ValidateUpdate(old); err == nil {
+		t.Error("expected the change from a headless Service to be rejected")
+	}
+}
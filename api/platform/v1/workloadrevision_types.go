@@ -0,0 +1,70 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkloadRevisionSpec defines a snapshot of the spec of a Workload
+type WorkloadRevisionSpec struct {
+	// Workload is the name of the Workload of this revision.
+	Workload string `json:"workload"`
+
+	// Revision is the number of this revision. It is increased when an
+	// earlier spec of the Workload is applied again.
+	// +kubebuilder:validation:Minimum=1
+	Revision int64 `json:"revision"`
+
+	// ChangeSummary lists the fields of the Workload spec that changed since
+	// the previous revision.
+	// +optional
+	ChangeSummary string `json:"changeSummary,omitempty"`
+
+	// Template is the spec of the Workload at this revision, without
+	// rollbackTo and paused.
+	Template WorkloadSpec `json:"template"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:shortName=wlrev,categories=platform
+//+kubebuilder:printcolumn:name="Workload",type=string,JSONPath=`.spec.workload`
+//+kubebuilder:printcolumn:name="Revision",type=integer,JSONPath=`.spec.revision`
+//+kubebuilder:printcolumn:name="Changes",type=string,JSONPath=`.spec.changeSummary`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// WorkloadRevision is the Schema for the workloadrevisions API. It is created
+// by the operator for every spec of a Workload.
+type WorkloadRevision struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WorkloadRevisionSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WorkloadRevisionList contains a list of WorkloadRevision
+type WorkloadRevisionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WorkloadRevision `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WorkloadRevision{}, &WorkloadRevisionList{})
+}
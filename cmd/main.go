@@ -17,7 +17,9 @@ limitations under the License.
 package main
 
 import (
+	"errors"
 	"flag"
+	"net/http"
 	"os"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -34,6 +36,7 @@ import (
 	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
 	platformv1 "mydev.org/platform-operator/api/platform/v1"
 
+	"mydev.org/platform-operator/internal/cert"
 	"mydev.org/platform-operator/internal/config"
 	controller "mydev.org/platform-operator/internal/controller/platform"
 	//+kubebuilder:scaffold:imports
@@ -106,20 +109,48 @@ func main() {
 		os.Exit(1)
 	}
 
+	certsReady := make(chan struct{})
+	if cfg.InternalCertManagement != nil && *cfg.InternalCertManagement.Enable {
+		if err = cert.ManageCerts(mgr, cfg, certsReady); err != nil {
+			setupLog.Error(err, "Unable to set up cert rotation")
+			os.Exit(1)
+		}
+	} else {
+		close(certsReady)
+	}
+
 	if err = (&controller.WorkloadReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Config:   cfg,
+		Recorder: mgr.GetEventRecorderFor("workload-controller"),
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Workload")
 		os.Exit(1)
 	}
 	//+kubebuilder:scaffold:builder
 
+	// The webhook server won't start until the certs are all in place.
+	enableWebhooks := os.Getenv("ENABLE_WEBHOOKS") != "false"
+	if enableWebhooks {
+		go setupWebhooks(mgr, certsReady)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
 	}
-	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
+	if err := mgr.AddReadyzCheck("readyz", func(req *http.Request) error {
+		select {
+		case <-certsReady:
+			if !enableWebhooks {
+				return nil
+			}
+			return mgr.GetWebhookServer().StartedChecker()(req)
+		default:
+			return errors.New("certificates are not ready")
+		}
+	}); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
@@ -131,6 +162,17 @@ func main() {
 	}
 }
 
+func setupWebhooks(mgr ctrl.Manager, certsReady <-chan struct{}) {
+	setupLog.Info("Waiting for certificate generation to complete")
+	<-certsReady
+	setupLog.Info("Certs ready")
+
+	if err := (&platformv1.Workload{}).SetupWebhookWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create webhook", "webhook", "Workload")
+		os.Exit(1)
+	}
+}
+
 func apply(configFile string) (ctrl.Options, configv1alpha1.OperatorConfig, error) {
 	options, cfg, err := config.Load(scheme, configFile)
 	if err != nil {
@@ -0,0 +1,543 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cert
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configapi "mydev.org/platform-operator/api/config/v1alpha1"
+)
+
+const (
+	caName         = "platform-operator-ca"
+	caOrganization = "platform-operator"
+
+	// names of the objects carrying the CA bundle, as deployed by config/default
+	mutatingWebhookConfigurationName   = "platform-operator-mutating-webhook-configuration"
+	validatingWebhookConfigurationName = "platform-operator-validating-webhook-configuration"
+	workloadsCRDName                   = "workloads.platform.mydev.org"
+	workloadRevisionsCRDName           = "workloadrevisions.platform.mydev.org"
+
+	// keys of the certificate Secret
+	caCertName   = "ca.crt"
+	caKeyName    = "ca.key"
+	caBundleName = "ca-bundle.crt"
+	certName     = "tls.crt"
+	keyName      = "tls.key"
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 365 * 24 * time.Hour
+
+	// lookahead rotates the certificates when they expire within this window
+	lookahead = 90 * 24 * time.Hour
+	// checkInterval is the interval at which the certificates are checked, it
+	// also bounds the time other replicas take to pick up rotated certificates
+	checkInterval = 10 * time.Minute
+)
+
+var certLog = ctrl.Log.WithName("cert-rotation")
+
+// These are the rules the cert rotation needs. They do not narrow its access to
+// Secrets: the Workload controller copies image pull secrets, so the manager may
+// write Secrets of all namespaces anyway. A renamed webhook Secret has to be
+// renamed in the resourceNames below as well.
+//+kubebuilder:rbac:groups=core,namespace=system,resources=secrets,verbs=create
+//+kubebuilder:rbac:groups=core,namespace=system,resources=secrets,resourceNames=platform-operator-webhook-server-cert,verbs=get;update
+//+kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=mutatingwebhookconfigurations,resourceNames=platform-operator-mutating-webhook-configuration,verbs=get;update
+//+kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=validatingwebhookconfigurations,resourceNames=platform-operator-validating-webhook-configuration,verbs=get;update
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,resourceNames=workloads.platform.mydev.org;workloadrevisions.platform.mydev.org,verbs=get;update
+
+// ManageCerts creates all certs for webhooks and keeps them valid. The
+// setupFinished channel is closed once the certs are written to the cert
+// directory of the webhook server. This function is called from main.go.
+func ManageCerts(mgr ctrl.Manager, cfg configapi.OperatorConfig, setupFinished chan struct{}) error {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+
+	// the cache is not started before the certs are in place, so use a direct client
+	c, err := client.New(mgr.GetConfig(), client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	certDir := cfg.Webhook.CertDir
+	if certDir == "" {
+		certDir = filepath.Join(os.TempDir(), "k8s-webhook-server", "serving-certs")
+	}
+
+	// DNSName is <service name>.<namespace>.svc
+	serviceName := *cfg.InternalCertManagement.WebhookServiceName
+	dnsNames := []string{
+		fmt.Sprintf("%s.%s.svc", serviceName, *cfg.Namespace),
+		fmt.Sprintf("%s.%s.svc.cluster.local", serviceName, *cfg.Namespace),
+	}
+
+	return mgr.Add(&rotator{
+		client: c,
+		secretKey: types.NamespacedName{
+			Namespace: *cfg.Namespace,
+			Name:      *cfg.InternalCertManagement.WebhookSecretName,
+		},
+		certDir:  certDir,
+		dnsNames: dnsNames,
+		isReady:  setupFinished,
+	})
+}
+
+// artifacts are the PEM encoded CA and serving certificates. The CA bundle
+// holds the current CA and the replaced CAs that did not expire yet.
+type artifacts struct {
+	caCert   []byte
+	caKey    []byte
+	caBundle []byte
+	cert     []byte
+	key      []byte
+}
+
+// rotator keeps the serving certificates of the webhook server valid. It runs
+// on every replica of the operator, regardless of leader election.
+type rotator struct {
+	client    client.Client
+	secretKey types.NamespacedName
+	certDir   string
+	dnsNames  []string
+	isReady   chan struct{}
+}
+
+// NeedLeaderElection implements manager.LeaderElectionRunnable
+func (r *rotator) NeedLeaderElection() bool {
+	return false
+}
+
+// Start implements manager.Runnable
+func (r *rotator) Start(ctx context.Context) error {
+	// retry until the certs are in place for the first time
+	if err := wait.PollUntilContextCancel(ctx, 5*time.Second, true, func(ctx context.Context) (bool, error) {
+		if err := r.refresh(ctx); err != nil {
+			certLog.Error(err, "Unable to refresh certs")
+			return false, nil
+		}
+		return true, nil
+	}); err != nil {
+		return err
+	}
+
+	certLog.Info("Certs ready", "certDir", r.certDir)
+	close(r.isReady)
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := r.refresh(ctx); err != nil {
+				certLog.Error(err, "Unable to refresh certs")
+			}
+		}
+	}
+}
+
+// refresh refreshes the certs, starting over when another replica of the
+// operator updated the Secret at the same time.
+func (r *rotator) refresh(ctx context.Context) error {
+	return retry.OnError(retry.DefaultRetry, func(err error) bool {
+		return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+	}, func() error {
+		return r.refreshCerts(ctx)
+	})
+}
+
+// refreshCerts makes sure the Secret holds valid certs, rotating them when
+// they expire soon, injects the CA bundle and writes the serving cert to the
+// cert directory.
+func (r *rotator) refreshCerts(ctx context.Context) error {
+	secret := &corev1.Secret{}
+	if err := r.client.Get(ctx, r.secretKey, secret); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return err
+		}
+		secret = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: r.secretKey.Namespace, Name: r.secretKey.Name},
+		}
+	}
+
+	current := artifactsFromSecret(secret)
+	desired, err := r.desiredCerts(current, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if !desired.equal(current) {
+		certLog.Info("Updating certs", "secret", r.secretKey)
+
+		secret.Data = map[string][]byte{
+			caCertName:   desired.caCert,
+			caKeyName:    desired.caKey,
+			caBundleName: desired.caBundle,
+			certName:     desired.cert,
+			keyName:      desired.key,
+		}
+
+		if secret.ResourceVersion == "" {
+			secret.Type = corev1.SecretTypeOpaque
+			if err := r.client.Create(ctx, secret); err != nil {
+				return err
+			}
+		} else if err := r.client.Update(ctx, secret); err != nil {
+			return err
+		}
+	}
+
+	// every replica injects the CA bundle before it serves a cert signed by a
+	// new CA, so the API server never sees a serving cert it does not trust
+	if err := r.injectCABundle(ctx, desired.caBundle); err != nil {
+		return err
+	}
+
+	return r.writeCerts(desired)
+}
+
+// desiredCerts returns the certs to use at the given time. The CA is reused as
+// long as it does not expire soon. A replaced CA stays in the CA bundle until
+// it expires, so that replicas of the operator which did not reload their
+// serving cert yet are still trusted.
+func (r *rotator) desiredCerts(current *artifacts, now time.Time) (*artifacts, error) {
+	desired := *current
+	at := now.Add(lookahead)
+
+	if !validCA(&desired, at) {
+		certLog.Info("Generating new CA", "secret", r.secretKey)
+
+		caCert, caKey, err := generateCA(now)
+		if err != nil {
+			return nil, err
+		}
+		desired.caCert, desired.caKey = caCert, caKey
+	}
+
+	if !r.validCerts(&desired, at) {
+		certLog.Info("Generating new serving cert", "secret", r.secretKey)
+
+		cert, key, err := generateServingCert(r.dnsNames, desired.caCert, desired.caKey, now)
+		if err != nil {
+			return nil, err
+		}
+		desired.cert, desired.key = cert, key
+	}
+
+	desired.caBundle = caBundle(now, desired.caCert, current.caBundle, current.caCert)
+
+	return &desired, nil
+}
+
+func (a *artifacts) equal(other *artifacts) bool {
+	return bytes.Equal(a.caCert, other.caCert) &&
+		bytes.Equal(a.caKey, other.caKey) &&
+		bytes.Equal(a.caBundle, other.caBundle) &&
+		bytes.Equal(a.cert, other.cert) &&
+		bytes.Equal(a.key, other.key)
+}
+
+func artifactsFromSecret(secret *corev1.Secret) *artifacts {
+	return &artifacts{
+		caCert:   secret.Data[caCertName],
+		caKey:    secret.Data[caKeyName],
+		caBundle: secret.Data[caBundleName],
+		cert:     secret.Data[certName],
+		key:      secret.Data[keyName],
+	}
+}
+
+// validCA reports whether the CA cert matches its key and is valid at the
+// given time.
+func validCA(certs *artifacts, at time.Time) bool {
+	if _, err := tls.X509KeyPair(certs.caCert, certs.caKey); err != nil {
+		return false
+	}
+
+	block, _ := pem.Decode(certs.caCert)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false
+	}
+
+	return cert.IsCA && at.After(cert.NotBefore) && at.Before(cert.NotAfter)
+}
+
+// caBundle returns the PEM encoded CA certs which did not expire at the given
+// time, without duplicates and in the given order.
+func caBundle(now time.Time, certs ...[]byte) []byte {
+	var bundle []byte
+	seen := map[string]bool{}
+	for _, rest := range certs {
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil || !cert.IsCA || !now.Before(cert.NotAfter) || seen[string(block.Bytes)] {
+				continue
+			}
+			seen[string(block.Bytes)] = true
+			bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: block.Bytes})...)
+		}
+	}
+
+	return bundle
+}
+
+// validCerts reports whether the serving cert matches its key and is signed
+// by the CA for all DNS names at the given time.
+func (r *rotator) validCerts(certs *artifacts, at time.Time) bool {
+	if _, err := tls.X509KeyPair(certs.cert, certs.key); err != nil {
+		return false
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(certs.caCert) {
+		return false
+	}
+
+	block, _ := pem.Decode(certs.cert)
+	if block == nil {
+		return false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return false
+	}
+
+	for _, dnsName := range r.dnsNames {
+		if _, err := cert.Verify(x509.VerifyOptions{DNSName: dnsName, Roots: pool, CurrentTime: at}); err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeCerts writes the serving cert to the cert directory of the webhook
+// server, which reloads it when the files change.
+func (r *rotator) writeCerts(certs *artifacts) error {
+	if err := os.MkdirAll(r.certDir, 0o700); err != nil {
+		return err
+	}
+
+	for name, content := range map[string][]byte{certName: certs.cert, keyName: certs.key} {
+		path := filepath.Join(r.certDir, name)
+		if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, content) {
+			continue
+		}
+
+		// write to a temporary file first so the webhook server never reads a partial file
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, content, 0o600); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// injectCABundle sets the CA bundle on the webhook configurations and the
+// conversion webhooks of the operator CRDs. Missing objects are skipped.
+func (r *rotator) injectCABundle(ctx context.Context, caBundle []byte) error {
+	var mutating admissionregistrationv1.MutatingWebhookConfiguration
+	if err := r.client.Get(ctx, types.NamespacedName{Name: mutatingWebhookConfigurationName}, &mutating); client.IgnoreNotFound(err) != nil {
+		return err
+	} else if err == nil {
+		changed := false
+		for i := range mutating.Webhooks {
+			if !bytes.Equal(mutating.Webhooks[i].ClientConfig.CABundle, caBundle) {
+				mutating.Webhooks[i].ClientConfig.CABundle = caBundle
+				changed = true
+			}
+		}
+		if changed {
+			if err := r.client.Update(ctx, &mutating); err != nil {
+				return err
+			}
+		}
+	}
+
+	var validating admissionregistrationv1.ValidatingWebhookConfiguration
+	if err := r.client.Get(ctx, types.NamespacedName{Name: validatingWebhookConfigurationName}, &validating); client.IgnoreNotFound(err) != nil {
+		return err
+	} else if err == nil {
+		changed := false
+		for i := range validating.Webhooks {
+			if !bytes.Equal(validating.Webhooks[i].ClientConfig.CABundle, caBundle) {
+				validating.Webhooks[i].ClientConfig.CABundle = caBundle
+				changed = true
+			}
+		}
+		if changed {
+			if err := r.client.Update(ctx, &validating); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, name := range []string{workloadsCRDName, workloadRevisionsCRDName} {
+		var crd apiextensionsv1.CustomResourceDefinition
+		if err := r.client.Get(ctx, types.NamespacedName{Name: name}, &crd); client.IgnoreNotFound(err) != nil {
+			return err
+		} else if err == nil {
+			conversion := crd.Spec.Conversion
+			if conversion != nil && conversion.Strategy == apiextensionsv1.WebhookConverter &&
+				conversion.Webhook != nil && conversion.Webhook.ClientConfig != nil &&
+				!bytes.Equal(conversion.Webhook.ClientConfig.CABundle, caBundle) {
+				conversion.Webhook.ClientConfig.CABundle = caBundle
+				if err := r.client.Update(ctx, &crd); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// generateCA creates a new self-signed CA.
+func generateCA(now time.Time) ([]byte, []byte, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: caName, Organization: []string{caOrganization}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	caKeyPEM, err := encodeKey(caKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER}), caKeyPEM, nil
+}
+
+// generateServingCert creates a serving cert for the DNS names signed by the
+// given CA.
+func generateServingCert(dnsNames []string, caCertPEM, caKeyPEM []byte, now time.Time) ([]byte, []byte, error) {
+	caPair, err := tls.X509KeyPair(caCertPEM, caKeyPEM)
+	if err != nil {
+		return nil, nil, err
+	}
+	caCert, err := x509.ParseCertificate(caPair.Certificate[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: dnsNames[0], Organization: []string{caOrganization}},
+		DNSNames:     dnsNames,
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.Add(certValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caPair.PrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyPEM, err := encodeKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), keyPEM, nil
+}
+
+// serialNumber returns a random 128 bit serial number, so that the certs of
+// consecutive CAs can be told apart.
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func encodeKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), nil
+}
@@ -0,0 +1,272 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cert
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestRotator(t *testing.T, objs ...client.Object) *rotator {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+
+	return &rotator{
+		client:    fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build(),
+		secretKey: types.NamespacedName{Namespace: "platform-operator-system", Name: "webhook-server-cert"},
+		certDir:   t.TempDir(),
+		dnsNames:  []string{"webhook-service.platform-operator-system.svc"},
+		isReady:   make(chan struct{}),
+	}
+}
+
+// bundleCerts returns the certs of a PEM encoded CA bundle.
+func bundleCerts(t *testing.T, bundle []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for rest := bundle; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return certs
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			t.Fatalf("parsing CA bundle: %v", err)
+		}
+		certs = append(certs, cert)
+	}
+}
+
+// verifiesWith reports whether the serving cert verifies against the bundle.
+func verifiesWith(t *testing.T, r *rotator, certs *artifacts, bundle []byte, at time.Time) bool {
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		t.Fatal("empty CA bundle")
+	}
+	block, _ := pem.Decode(certs.cert)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("parsing serving cert: %v", err)
+	}
+	_, err = cert.Verify(x509.VerifyOptions{DNSName: r.dnsNames[0], Roots: pool, CurrentTime: at})
+	return err == nil
+}
+
+func TestDesiredCertsKeepsValidCerts(t *testing.T) {
+	r := newTestRotator(t)
+	now := time.Now()
+
+	initial, err := r.desiredCerts(&artifacts{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(bundleCerts(t, initial.caBundle)); n != 1 {
+		t.Fatalf("expected 1 CA in the bundle, got %d", n)
+	}
+
+	again, err := r.desiredCerts(initial, now.Add(checkInterval))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !again.equal(initial) {
+		t.Error("expected valid certs to be kept")
+	}
+}
+
+func TestDesiredCertsReusesCA(t *testing.T) {
+	r := newTestRotator(t)
+	now := time.Now()
+
+	initial, err := r.desiredCerts(&artifacts{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the serving cert expires within the lookahead, the CA does not
+	later := now.Add(certValidity - lookahead + time.Hour)
+	rotated, err := r.desiredCerts(initial, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(rotated.cert, initial.cert) {
+		t.Error("expected a new serving cert")
+	}
+	if !bytes.Equal(rotated.caCert, initial.caCert) || !bytes.Equal(rotated.caKey, initial.caKey) {
+		t.Error("expected the CA to be reused")
+	}
+	if !bytes.Equal(rotated.caBundle, initial.caBundle) {
+		t.Error("expected the CA bundle to be unchanged")
+	}
+	if !verifiesWith(t, r, rotated, initial.caBundle, later) {
+		t.Error("expected the new serving cert to verify against the injected CA bundle")
+	}
+}
+
+func TestDesiredCertsRotatesCA(t *testing.T) {
+	r := newTestRotator(t)
+	now := time.Now()
+
+	first, err := r.desiredCerts(&artifacts{}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the last serving cert signed by the first CA
+	before := now.Add(caValidity - lookahead - 30*24*time.Hour)
+	initial, err := r.desiredCerts(first, before)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(initial.caCert, first.caCert) {
+		t.Fatal("expected the CA to be reused")
+	}
+
+	// the CA expires within the lookahead
+	later := before.Add(30*24*time.Hour + time.Hour)
+	rotated, err := r.desiredCerts(initial, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(rotated.caCert, initial.caCert) {
+		t.Fatal("expected a new CA")
+	}
+	bundle := bundleCerts(t, rotated.caBundle)
+	if len(bundle) != 2 {
+		t.Fatalf("expected the new and the old CA in the bundle, got %d certs", len(bundle))
+	}
+
+	// replicas which did not reload yet serve the old cert
+	if !verifiesWith(t, r, initial, rotated.caBundle, later) {
+		t.Error("expected the old serving cert to verify against the CA bundle")
+	}
+	if !verifiesWith(t, r, rotated, rotated.caBundle, later) {
+		t.Error("expected the new serving cert to verify against the CA bundle")
+	}
+
+	// the old CA is dropped once it expired
+	expired := now.Add(caValidity + time.Hour)
+	next, err := r.desiredCerts(rotated, expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(next.caCert, rotated.caCert) {
+		t.Error("expected the new CA to be reused")
+	}
+	if n := len(bundleCerts(t, next.caBundle)); n != 1 {
+		t.Errorf("expected the expired CA to be dropped from the bundle, got %d certs", n)
+	}
+}
+
+func TestRefreshCerts(t *testing.T) {
+	caBundleBefore := []byte("stale")
+	webhookConfig := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		ObjectMeta: metav1.ObjectMeta{Name: validatingWebhookConfigurationName},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{{
+			Name:         "vworkload.kb.io",
+			ClientConfig: admissionregistrationv1.WebhookClientConfig{CABundle: caBundleBefore},
+		}},
+	}
+	crds := []client.Object{}
+	for _, name := range []string{workloadsCRDName, workloadRevisionsCRDName} {
+		crds = append(crds, &apiextensionsv1.CustomResourceDefinition{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+				Conversion: &apiextensionsv1.CustomResourceConversion{
+					Strategy: apiextensionsv1.WebhookConverter,
+					Webhook: &apiextensionsv1.WebhookConversion{
+						ClientConfig: &apiextensionsv1.WebhookClientConfig{CABundle: caBundleBefore},
+					},
+				},
+			},
+		})
+	}
+	r := newTestRotator(t, append(crds, webhookConfig)...)
+	ctx := context.Background()
+
+	if err := r.refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var secret corev1.Secret
+	if err := r.client.Get(ctx, r.secretKey, &secret); err != nil {
+		t.Fatal(err)
+	}
+	stored := artifactsFromSecret(&secret)
+	if len(stored.caBundle) == 0 {
+		t.Fatal("expected the CA bundle to be stored")
+	}
+
+	for name, content := range map[string][]byte{certName: stored.cert, keyName: stored.key} {
+		written, err := os.ReadFile(filepath.Join(r.certDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(written, content) {
+			t.Errorf("expected %s to be written to the cert directory", name)
+		}
+	}
+
+	if err := r.client.Get(ctx, client.ObjectKeyFromObject(webhookConfig), webhookConfig); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(webhookConfig.Webhooks[0].ClientConfig.CABundle, stored.caBundle) {
+		t.Error("expected the CA bundle to be injected into the webhook configuration")
+	}
+	for _, obj := range crds {
+		crd := obj.(*apiextensionsv1.CustomResourceDefinition)
+		if err := r.client.Get(ctx, client.ObjectKeyFromObject(crd), crd); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(crd.Spec.Conversion.Webhook.ClientConfig.CABundle, stored.caBundle) {
+			t.Errorf("expected the CA bundle to be injected into CRD %s", crd.Name)
+		}
+	}
+
+	// another replica reuses the stored certs
+	other := newTestRotator(t)
+	other.client = r.client
+	if err := other.refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	var after corev1.Secret
+	if err := r.client.Get(ctx, r.secretKey, &after); err != nil {
+		t.Fatal(err)
+	}
+	if after.ResourceVersion != secret.ResourceVersion {
+		t.Error("expected the Secret to be unchanged when the certs are valid")
+	}
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// replicasFieldOwner is the field manager that keeps the replica count of a
+// Deployment while its ownership is handed over to the HorizontalPodAutoscaler
+const replicasFieldOwner = "workload-controller-replicas"
+
+func (r *WorkloadReconciler) desiredHorizontalPodAutoscaler(workload platformv1.Workload, target client.Object) (autoscalingv2.HorizontalPodAutoscaler, error) {
+	autoscaling := workload.Spec.Autoscaling
+
+	var metrics []autoscalingv2.MetricSpec
+	resourceTargets := []struct {
+		name   corev1.ResourceName
+		target *int32
+	}{
+		{corev1.ResourceCPU, autoscaling.TargetCPUUtilizationPercentage},
+		{corev1.ResourceMemory, autoscaling.TargetMemoryUtilizationPercentage},
+	}
+	for _, resource := range resourceTargets {
+		if resource.target == nil {
+			continue
+		}
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.ResourceMetricSourceType,
+			Resource: &autoscalingv2.ResourceMetricSource{
+				Name: resource.name,
+				Target: autoscalingv2.MetricTarget{
+					Type:               autoscalingv2.UtilizationMetricType,
+					AverageUtilization: resource.target,
+				},
+			},
+		})
+	}
+	for _, metric := range autoscaling.CustomMetrics {
+		averageValue := metric.AverageValue.DeepCopy()
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.PodsMetricSourceType,
+			Pods: &autoscalingv2.PodsMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{
+					Name:     metric.Name,
+					Selector: metric.Selector,
+				},
+				Target: autoscalingv2.MetricTarget{
+					Type:         autoscalingv2.AverageValueMetricType,
+					AverageValue: &averageValue,
+				},
+			},
+		})
+	}
+
+	var behavior *autoscalingv2.HorizontalPodAutoscalerBehavior
+	if autoscaling.ScaleDownStabilizationWindowSeconds != nil {
+		behavior = &autoscalingv2.HorizontalPodAutoscalerBehavior{
+			ScaleDown: &autoscalingv2.HPAScalingRules{
+				StabilizationWindowSeconds: autoscaling.ScaleDownStabilizationWindowSeconds,
+			},
+		}
+	}
+
+	hpa := autoscalingv2.HorizontalPodAutoscaler{
+		TypeMeta: metav1.TypeMeta{APIVersion: autoscalingv2.SchemeGroupVersion.String(), Kind: "HorizontalPodAutoscaler"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workload.Name,
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: target.GetObjectKind().GroupVersionKind().GroupVersion().String(),
+				Kind:       target.GetObjectKind().GroupVersionKind().Kind,
+				Name:       target.GetName(),
+			},
+			MinReplicas: autoscaling.MinReplicas,
+			MaxReplicas: autoscaling.MaxReplicas,
+			Metrics:     metrics,
+			Behavior:    behavior,
+		},
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, &hpa, r.Scheme); err != nil {
+		return hpa, err
+	}
+
+	return hpa, nil
+}
+
+// handOverReplicas keeps the replica count of the Deployment or StatefulSet of
+// an autoscaled workload under a separate field manager before the controller
+// stops applying it. Without it, the API server would reset the replicas to 1
+// until the HorizontalPodAutoscaler writes them and takes the ownership.
+func (r *WorkloadReconciler) handOverReplicas(ctx context.Context, target client.Object) error {
+	log := log.
+		FromContext(ctx)
+
+	gvk := target.GetObjectKind().GroupVersionKind()
+	existing := target.DeepCopyObject().(client.Object)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(target), existing); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	var replicas *int32
+	switch existing := existing.(type) {
+	case *appsv1.Deployment:
+		replicas = existing.Spec.Replicas
+	case *appsv1.StatefulSet:
+		replicas = existing.Spec.Replicas
+	}
+
+	if replicas == nil || !managesReplicas(existing.GetManagedFields(), fieldOwner) {
+		return nil
+	}
+
+	log.Info("handing over replicas to the HorizontalPodAutoscaler", "kind", gvk.Kind, "replicas", *replicas)
+	handover := &unstructured.Unstructured{}
+	handover.SetGroupVersionKind(gvk)
+	handover.SetName(target.GetName())
+	handover.SetNamespace(target.GetNamespace())
+	if err := unstructured.SetNestedField(handover.Object, int64(*replicas), "spec", "replicas"); err != nil {
+		return err
+	}
+
+	return r.Patch(ctx, handover, client.Apply, client.FieldOwner(replicasFieldOwner))
+}
+
+// managesReplicas reports whether the field manager applied the replicas of a Deployment.
+func managesReplicas(managedFields []metav1.ManagedFieldsEntry, manager string) bool {
+	for _, entry := range managedFields {
+		if entry.Manager != manager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+
+		var fields map[string]map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields["f:spec"]["f:replicas"]; ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+func TestDesiredHorizontalPodAutoscaler(t *testing.T) {
+	workload := testWorkload("app:v1")
+	workload.Spec.Autoscaling = &platformv1.AutoscalingSpec{
+		MinReplicas:                    pointer.Int32(2),
+		MaxReplicas:                    10,
+		TargetCPUUtilizationPercentage: pointer.Int32(70),
+		CustomMetrics: []platformv1.CustomMetricTarget{
+			{Name: "requests_per_second", AverageValue: resource.MustParse("100")},
+		},
+		ScaleDownStabilizationWindowSeconds: pointer.Int32(60),
+	}
+
+	hpa, err := newTestReconciler().desiredHorizontalPodAutoscaler(*workload, testDeployment("web", "app:v1", 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOwned(t, &hpa)
+
+	spec := hpa.Spec
+	if target := spec.ScaleTargetRef; target.APIVersion != "apps/v1" || target.Kind != "Deployment" || target.Name != "web" {
+		t.Errorf("expected the Deployment web to be scaled, got %v", target)
+	}
+	if *spec.MinReplicas != 2 || spec.MaxReplicas != 10 {
+		t.Errorf("expected 2 to 10 replicas, got %d to %d", *spec.MinReplicas, spec.MaxReplicas)
+	}
+	if len(spec.Metrics) != 2 {
+		t.Fatalf("expected the CPU and the custom metric, got %v", spec.Metrics)
+	}
+	if cpu := spec.Metrics[0]; cpu.Type != autoscalingv2.ResourceMetricSourceType || cpu.Resource.Name != "cpu" ||
+		*cpu.Resource.Target.AverageUtilization != 70 {
+		t.Errorf("expected a CPU target of 70%%, got %v", cpu)
+	}
+	if custom := spec.Metrics[1]; custom.Type != autoscalingv2.PodsMetricSourceType || custom.Pods.Metric.Name != "requests_per_second" ||
+		custom.Pods.Target.AverageValue.Cmp(resource.MustParse("100")) != 0 {
+		t.Errorf("expected a target of 100 requests per second, got %v", custom)
+	}
+	if spec.Behavior == nil || *spec.Behavior.ScaleDown.StabilizationWindowSeconds != 60 {
+		t.Errorf("expected a scale down stabilization window of 60 seconds, got %v", spec.Behavior)
+	}
+}
+
+func TestManagesReplicas(t *testing.T) {
+	applied := func(manager, fields string) metav1.ManagedFieldsEntry {
+		entry := managedFieldsEntry(manager, "", fields)
+		entry.Operation = metav1.ManagedFieldsOperationApply
+		return entry
+	}
+
+	tests := []struct {
+		name  string
+		entry metav1.ManagedFieldsEntry
+		want  bool
+	}{
+		{name: "applied replicas", entry: applied(fieldOwner, `{"f:spec":{"f:replicas":{}}}`), want: true},
+		{name: "applied without replicas", entry: applied(fieldOwner, `{"f:spec":{"f:template":{}}}`)},
+		{name: "replicas of another manager", entry: applied(replicasFieldOwner, `{"f:spec":{"f:replicas":{}}}`)},
+		{name: "updated replicas", entry: managedFieldsEntry(fieldOwner, "", `{"f:spec":{"f:replicas":{}}}`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := managesReplicas([]metav1.ManagedFieldsEntry{tt.entry}, fieldOwner); got != tt.want {
+				t.Errorf("managesReplicas() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandOverReplicas(t *testing.T) {
+	ctx := context.Background()
+	deployment := testDeployment("web", "app:v1", 4)
+	deployment.ManagedFields = []metav1.ManagedFieldsEntry{
+		managedFieldsEntry(fieldOwner, "", `{"f:spec":{"f:replicas":{}}}`),
+	}
+	deployment.ManagedFields[0].Operation = metav1.ManagedFieldsOperationApply
+
+	var owner string
+	r := newTestReconciler(deployment)
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			patchOpts := &client.PatchOptions{}
+			patchOpts.ApplyOptions(opts)
+			owner = patchOpts.FieldManager
+			return c.Patch(ctx, obj, patch, opts...)
+		},
+	})
+
+	// the autoscaled Deployment is applied without replicas
+	if err := r.handOverReplicas(ctx, testDeployment("web", "app:v1", 0)); err != nil {
+		t.Fatal(err)
+	}
+	if owner != replicasFieldOwner {
+		t.Fatalf("expected the replicas to be handed over to %s, got %q", replicasFieldOwner, owner)
+	}
+	var live appsv1.Deployment
+	if err := r.Get(ctx, client.ObjectKeyFromObject(deployment), &live); err != nil {
+		t.Fatal(err)
+	}
+	if desiredReplicas(&live) != 4 {
+		t.Errorf("expected the Deployment to keep 4 replicas, got %d", desiredReplicas(&live))
+	}
+}
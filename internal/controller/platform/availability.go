@@ -0,0 +1,156 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// availabilityRules returns the rules configured for the availability class of the workload.
+func (r *WorkloadReconciler) availabilityRules(workload platformv1.Workload) configv1alpha1.AvailabilityClassRules {
+	var rules *configv1alpha1.AvailabilityClassRules
+	switch workload.Spec.Availability {
+	case platformv1.AvailabilityClassCritical:
+		rules = r.Config.Availability.Critical
+	case platformv1.AvailabilityClassBestEffort:
+		rules = r.Config.Availability.BestEffort
+	default:
+		rules = r.Config.Availability.Standard
+	}
+
+	if rules == nil {
+		return configv1alpha1.AvailabilityClassRules{}
+	}
+
+	return *rules
+}
+
+// desiredPodDisruptionBudget returns the PodDisruptionBudget of the workload, or
+// nil when the availability class of the workload has no disruption budget or
+// its pods run to completion or it runs no pods.
+func (r *WorkloadReconciler) desiredPodDisruptionBudget(workload platformv1.Workload) (*policyv1.PodDisruptionBudget, error) {
+	rules := r.availabilityRules(workload)
+	if !runsPods(workload) || isBatch(workload) || (rules.MinAvailable == nil && rules.MaxUnavailable == nil) {
+		return nil, nil
+	}
+
+	pdb := &policyv1.PodDisruptionBudget{
+		TypeMeta: metav1.TypeMeta{APIVersion: policyv1.SchemeGroupVersion.String(), Kind: "PodDisruptionBudget"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workload.Name,
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: policyv1.PodDisruptionBudgetSpec{
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selectorLabels(workload),
+			},
+		},
+	}
+
+	// only one of minAvailable and maxUnavailable may be set
+	if rules.MinAvailable != nil {
+		pdb.Spec.MinAvailable = rules.MinAvailable
+	} else {
+		pdb.Spec.MaxUnavailable = rules.MaxUnavailable
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, pdb, r.Scheme); err != nil {
+		return pdb, err
+	}
+
+	return pdb, nil
+}
+
+// disruptionsBlocked reports whether the PodDisruptionBudget of the workload
+// allows no voluntary disruption of its pods, so that node drains wait until
+// the workload is scaled up, e.g. the minimum of critical workloads with a
+// single replica. Autoscaled workloads are checked at their minimum replicas.
+// It returns the replicas checked.
+func disruptionsBlocked(workload platformv1.Workload, pdb *policyv1.PodDisruptionBudget) (int32, bool) {
+	replicas := int32(1)
+	switch {
+	case workload.Spec.Autoscaling != nil && workload.Spec.Autoscaling.MinReplicas != nil:
+		replicas = *workload.Spec.Autoscaling.MinReplicas
+	case workload.Spec.Autoscaling == nil && workload.Spec.Replicas != nil:
+		replicas = *workload.Spec.Replicas
+	}
+	if replicas == 0 {
+		return replicas, false
+	}
+
+	if pdb.Spec.MinAvailable != nil {
+		minAvailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MinAvailable, int(replicas), true)
+		return replicas, err == nil && minAvailable >= int(replicas)
+	}
+	maxUnavailable, err := intstr.GetScaledValueFromIntOrPercent(pdb.Spec.MaxUnavailable, int(replicas), true)
+	return replicas, err == nil && maxUnavailable <= 0
+}
+
+// podAffinity returns the pod anti-affinity for the availability class of the workload.
+func (r *WorkloadReconciler) podAffinity(workload platformv1.Workload) *corev1.Affinity {
+	term := corev1.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{
+			MatchLabels: selectorLabels(workload),
+		},
+		TopologyKey: corev1.LabelHostname,
+	}
+
+	switch r.availabilityRules(workload).PodAntiAffinity {
+	case configv1alpha1.PodAntiAffinityPreferred:
+		return &corev1.Affinity{
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{
+					{Weight: 100, PodAffinityTerm: term},
+				},
+			},
+		}
+	case configv1alpha1.PodAntiAffinityRequired:
+		return &corev1.Affinity{
+			PodAntiAffinity: &corev1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{term},
+			},
+		}
+	default:
+		return nil
+	}
+}
+
+// topologySpreadConstraints returns the pod spreading for the availability class of the workload.
+func (r *WorkloadReconciler) topologySpreadConstraints(workload platformv1.Workload) []corev1.TopologySpreadConstraint {
+	var constraints []corev1.TopologySpreadConstraint
+	for _, spread := range r.availabilityRules(workload).TopologySpread {
+		constraints = append(constraints, corev1.TopologySpreadConstraint{
+			MaxSkew:           spread.MaxSkew,
+			TopologyKey:       spread.TopologyKey,
+			WhenUnsatisfiable: spread.WhenUnsatisfiable,
+			LabelSelector: &metav1.LabelSelector{
+				MatchLabels: selectorLabels(workload),
+			},
+		})
+	}
+
+	return constraints
+}
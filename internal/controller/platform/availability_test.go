@@ -0,0 +1,113 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+func TestDesiredPodDisruptionBudget(t *testing.T) {
+	r := newTestReconciler()
+	minAvailable := intstr.FromString("75%")
+	maxUnavailable := intstr.FromInt(1)
+
+	tests := []struct {
+		name               string
+		availability       platformv1.AvailabilityClass
+		replicas           *int32
+		autoscaling        *platformv1.AutoscalingSpec
+		wantMinAvailable   *intstr.IntOrString
+		wantMaxUnavailable *intstr.IntOrString
+		wantBlocked        bool
+	}{
+		{
+			name:             "critical",
+			availability:     platformv1.AvailabilityClassCritical,
+			replicas:         pointer.Int32(4),
+			wantMinAvailable: &minAvailable,
+		},
+		{
+			name:             "critical with a single replica",
+			availability:     platformv1.AvailabilityClassCritical,
+			wantMinAvailable: &minAvailable,
+			wantBlocked:      true,
+		},
+		{
+			name:             "critical autoscaled from a single replica",
+			availability:     platformv1.AvailabilityClassCritical,
+			autoscaling:      &platformv1.AutoscalingSpec{MinReplicas: pointer.Int32(1), MaxReplicas: 5},
+			wantMinAvailable: &minAvailable,
+			wantBlocked:      true,
+		},
+		{
+			name:               "standard",
+			availability:       platformv1.AvailabilityClassStandard,
+			replicas:           pointer.Int32(4),
+			wantMaxUnavailable: &maxUnavailable,
+		},
+		{
+			name:               "standard with a single replica",
+			availability:       platformv1.AvailabilityClassStandard,
+			replicas:           pointer.Int32(1),
+			wantMaxUnavailable: &maxUnavailable,
+		},
+		{
+			name:         "best-effort",
+			availability: platformv1.AvailabilityClassBestEffort,
+			replicas:     pointer.Int32(4),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := testWorkload("app:v1")
+			workload.Spec.Availability = tt.availability
+			workload.Spec.Replicas = tt.replicas
+			workload.Spec.Autoscaling = tt.autoscaling
+
+			pdb, err := r.desiredPodDisruptionBudget(*workload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantMinAvailable == nil && tt.wantMaxUnavailable == nil {
+				if pdb != nil {
+					t.Errorf("expected no PodDisruptionBudget, got %v", pdb.Spec)
+				}
+				return
+			}
+			if pdb == nil {
+				t.Fatal("expected a PodDisruptionBudget")
+			}
+			if got := pdb.Spec.MinAvailable; (got == nil) != (tt.wantMinAvailable == nil) ||
+				got != nil && *got != *tt.wantMinAvailable {
+				t.Errorf("minAvailable = %v, want %v", got, tt.wantMinAvailable)
+			}
+			if got := pdb.Spec.MaxUnavailable; (got == nil) != (tt.wantMaxUnavailable == nil) ||
+				got != nil && *got != *tt.wantMaxUnavailable {
+				t.Errorf("maxUnavailable = %v, want %v", got, tt.wantMaxUnavailable)
+			}
+			if _, blocked := disruptionsBlocked(*workload, pdb); blocked != tt.wantBlocked {
+				t.Errorf("disruptionsBlocked() = %v, want %v", blocked, tt.wantBlocked)
+			}
+		})
+	}
+}
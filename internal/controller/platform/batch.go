@@ -0,0 +1,141 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// isBatch reports whether the workload runs its pods to completion.
+func isBatch(workload platformv1.Workload) bool {
+	return workload.Spec.Kind == platformv1.WorkloadKindJob || workload.Spec.Kind == platformv1.WorkloadKindCronJob
+}
+
+// jobSpec returns the spec of the Job, or of the jobs of the CronJob, of the workload.
+func jobSpec(workload platformv1.Workload, podTemplate corev1.PodTemplateSpec) batchv1.JobSpec {
+	var job platformv1.JobSpec
+	if workload.Spec.Job != nil {
+		job = *workload.Spec.Job
+	}
+
+	podTemplate.Spec.RestartPolicy = corev1.RestartPolicyOnFailure
+	if job.RestartPolicy != "" {
+		podTemplate.Spec.RestartPolicy = job.RestartPolicy
+	}
+
+	return batchv1.JobSpec{
+		BackoffLimit:          job.BackoffLimit,
+		ActiveDeadlineSeconds: job.ActiveDeadlineSeconds,
+		Template:              podTemplate,
+	}
+}
+
+// specHash returns a short hash of the spec that is safe to use in names.
+func specHash(spec interface{}) (string, error) {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return "", err
+	}
+	hash := fnv.New32a()
+	hash.Write(data)
+
+	return rand.SafeEncodeString(fmt.Sprint(hash.Sum32())), nil
+}
+
+// desiredJob returns the Job of the workload. The pod template of a Job can not
+// be changed, so the Job is named after a hash of its spec: a changed workload
+// runs a new Job and the previous one is pruned.
+func (r *WorkloadReconciler) desiredJob(workload platformv1.Workload, podTemplate corev1.PodTemplateSpec) (*batchv1.Job, error) {
+	spec := jobSpec(workload, podTemplate)
+
+	hash, err := specHash(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{APIVersion: batchv1.SchemeGroupVersion.String(), Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%s", workload.Name, hash),
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: spec,
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, job, r.Scheme); err != nil {
+		return job, err
+	}
+
+	return job, nil
+}
+
+// desiredCronJob returns the CronJob of the workload.
+func (r *WorkloadReconciler) desiredCronJob(workload platformv1.Workload, podTemplate corev1.PodTemplateSpec) (*batchv1.CronJob, error) {
+	var job platformv1.JobSpec
+	if workload.Spec.Job != nil {
+		job = *workload.Spec.Job
+	}
+
+	concurrencyPolicy := batchv1.ForbidConcurrent
+	if job.ConcurrencyPolicy != "" {
+		concurrencyPolicy = batchv1.ConcurrencyPolicy(job.ConcurrencyPolicy)
+	}
+
+	spec := jobSpec(workload, podTemplate)
+	spec.TTLSecondsAfterFinished = job.TTLSecondsAfterFinished
+
+	cronJob := &batchv1.CronJob{
+		TypeMeta: metav1.TypeMeta{APIVersion: batchv1.SchemeGroupVersion.String(), Kind: "CronJob"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workload.Name,
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: batchv1.CronJobSpec{
+			Schedule:                   workload.Spec.Schedule,
+			ConcurrencyPolicy:          concurrencyPolicy,
+			StartingDeadlineSeconds:    job.StartingDeadlineSeconds,
+			SuccessfulJobsHistoryLimit: job.SuccessfulJobsHistoryLimit,
+			FailedJobsHistoryLimit:     job.FailedJobsHistoryLimit,
+			JobTemplate: batchv1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: selectorLabels(workload),
+				},
+				Spec: spec,
+			},
+		},
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, cronJob, r.Scheme); err != nil {
+		return cronJob, err
+	}
+
+	return cronJob, nil
+}
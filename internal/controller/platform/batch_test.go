@@ -0,0 +1,150 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// batchWorkload returns the workload "web" running the image as a Job or CronJob.
+func batchWorkload(kind platformv1.WorkloadKind, image string) *platformv1.Workload {
+	workload := testWorkload(image)
+	workload.Spec.Kind = kind
+	workload.Spec.Replicas = nil
+	if kind == platformv1.WorkloadKindCronJob {
+		workload.Spec.Schedule = "0 * * * *"
+	}
+
+	return workload
+}
+
+func TestDesiredJob(t *testing.T) {
+	r := newTestReconciler()
+	workload := batchWorkload(platformv1.WorkloadKindJob, "app:v1")
+	if !isBatch(*workload) {
+		t.Fatal("expected a Job workload to run to completion")
+	}
+
+	job, err := r.desiredJob(*workload, testPodTemplate("app:v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOwned(t, job)
+	if !strings.HasPrefix(job.Name, "web-") {
+		t.Errorf("expected the Job to be named after the workload, got %s", job.Name)
+	}
+	if got := job.Spec.Template.Spec.RestartPolicy; got != corev1.RestartPolicyOnFailure {
+		t.Errorf("expected the pods to restart on failure, got %s", got)
+	}
+
+	// the pod template of a Job can not be changed, a new Job is run instead
+	updated, err := r.desiredJob(*workload, testPodTemplate("app:v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Name == job.Name {
+		t.Errorf("expected a new Job for a new image, got %s again", job.Name)
+	}
+
+	workload.Spec.Job = &platformv1.JobSpec{RestartPolicy: corev1.RestartPolicyNever, BackoffLimit: pointer.Int32(2)}
+	job, err = r.desiredJob(*workload, testPodTemplate("app:v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.Spec.Template.Spec.RestartPolicy != corev1.RestartPolicyNever || *job.Spec.BackoffLimit != 2 {
+		t.Errorf("expected the job settings of the workload, got %v", job.Spec)
+	}
+}
+
+func TestDesiredCronJob(t *testing.T) {
+	r := newTestReconciler()
+	workload := batchWorkload(platformv1.WorkloadKindCronJob, "app:v1")
+
+	cronJob, err := r.desiredCronJob(*workload, testPodTemplate("app:v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOwned(t, cronJob)
+	if cronJob.Name != "web" || cronJob.Spec.Schedule != "0 * * * *" {
+		t.Errorf("expected the CronJob web at 0 * * * *, got %s at %s", cronJob.Name, cronJob.Spec.Schedule)
+	}
+	if cronJob.Spec.ConcurrencyPolicy != batchv1.ForbidConcurrent {
+		t.Errorf("expected concurrent runs to be forbidden, got %s", cronJob.Spec.ConcurrencyPolicy)
+	}
+
+	workload.Spec.Job = &platformv1.JobSpec{
+		ConcurrencyPolicy:       platformv1.ConcurrencyPolicyReplace,
+		TTLSecondsAfterFinished: pointer.Int32(600),
+	}
+	cronJob, err = r.desiredCronJob(*workload, testPodTemplate("app:v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cronJob.Spec.ConcurrencyPolicy != batchv1.ReplaceConcurrent || *cronJob.Spec.JobTemplate.Spec.TTLSecondsAfterFinished != 600 {
+		t.Errorf("expected the job settings of the workload, got %v", cronJob.Spec)
+	}
+}
+
+func TestSetJobConditions(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition *batchv1.JobCondition
+		wantPhase platformv1.WorkloadPhase
+		wantReady metav1.ConditionStatus
+	}{
+		{name: "running", wantPhase: platformv1.WorkloadPhaseProgressing, wantReady: metav1.ConditionFalse},
+		{
+			name:      "complete",
+			condition: &batchv1.JobCondition{Type: batchv1.JobComplete, Status: corev1.ConditionTrue},
+			wantPhase: platformv1.WorkloadPhaseReady,
+			wantReady: metav1.ConditionTrue,
+		},
+		{
+			name:      "failed",
+			condition: &batchv1.JobCondition{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "BackoffLimitExceeded"},
+			wantPhase: platformv1.WorkloadPhaseDegraded,
+			wantReady: metav1.ConditionFalse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := batchWorkload(platformv1.WorkloadKindJob, "app:v1")
+			job := &batchv1.Job{ObjectMeta: metav1.ObjectMeta{Name: "web-1"}}
+			if tt.condition != nil {
+				job.Status.Conditions = []batchv1.JobCondition{*tt.condition}
+			}
+
+			setJobConditions(workload, job)
+			if workload.Status.Phase != tt.wantPhase {
+				t.Errorf("expected the phase %s, got %s", tt.wantPhase, workload.Status.Phase)
+			}
+			expectConditions(t, workload.Status.Conditions, map[string]metav1.ConditionStatus{
+				typeReadyWorkload:            tt.wantReady,
+				resourceConditionType("Job"): tt.wantReady,
+			})
+		})
+	}
+}
@@ -0,0 +1,359 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+const (
+	// colorLabel tells the pods of the two Deployments of a blue/green workload apart
+	colorLabel = "platform.mydev.org/color"
+
+	// templateHashAnnotation is set on the Deployments of a blue/green workload
+	// to the hash of the pod template they were created for
+	templateHashAnnotation = "platform.mydev.org/template-hash"
+
+	// promoteAnnotation switches the Service of a blue/green workload to its preview
+	promoteAnnotation = "platform.mydev.org/promote"
+
+	// rollbackAnnotation switches the Service of a blue/green workload back to its previous color
+	rollbackAnnotation = "platform.mydev.org/rollback"
+
+	// activeAnnotation marks the Deployment of the active color, so that the
+	// active color outlives the status of the workload
+	activeAnnotation = "platform.mydev.org/active"
+
+	previewSuffix = "-preview"
+)
+
+// usesBlueGreen reports whether changes of the workload are rolled out by
+// blue/green Deployments.
+func usesBlueGreen(workload platformv1.Workload) bool {
+	rollout := workload.Spec.Rollout
+	return rollout != nil && rollout.Strategy == platformv1.RolloutStrategyBlueGreen &&
+		!isBatch(workload) && workload.Spec.Storage == nil
+}
+
+// otherColor returns the color that is not the given one.
+func otherColor(color platformv1.DeploymentColor) platformv1.DeploymentColor {
+	if color == platformv1.DeploymentColorBlue {
+		return platformv1.DeploymentColorGreen
+	}
+
+	return platformv1.DeploymentColorBlue
+}
+
+// colorName returns the name of the Deployment of the given color.
+func colorName(workload platformv1.Workload, color platformv1.DeploymentColor) string {
+	return fmt.Sprintf("%s-%s", workload.Name, color)
+}
+
+// activeColor returns the color selected by the Service of a blue/green workload.
+func activeColor(workload platformv1.Workload) platformv1.DeploymentColor {
+	if workload.Status.BlueGreen == nil {
+		return platformv1.DeploymentColorBlue
+	}
+
+	return workload.Status.BlueGreen.ActiveColor
+}
+
+// recordedActiveColor returns the color marked active on the live Deployments
+// of a blue/green workload. When the Deployments do not tell, e.g. while both
+// are marked during a switch, the status of the workload decides.
+func recordedActiveColor(workload platformv1.Workload, live map[platformv1.DeploymentColor]*appsv1.Deployment) platformv1.DeploymentColor {
+	var marked []platformv1.DeploymentColor
+	for _, color := range []platformv1.DeploymentColor{platformv1.DeploymentColorBlue, platformv1.DeploymentColorGreen} {
+		if deployment := live[color]; deployment != nil && deployment.Annotations[activeAnnotation] == "true" {
+			marked = append(marked, color)
+		}
+	}
+	if len(marked) == 1 {
+		return marked[0]
+	}
+
+	return activeColor(workload)
+}
+
+// blueGreenRollout returns the active Deployment of the workload, and the
+// Deployment of the other color: the preview of a changed spec, or the
+// previous color kept for a rollback. It also returns the promote and rollback
+// annotations it acted upon, to be removed once the status is saved.
+func (r *WorkloadReconciler) blueGreenRollout(ctx context.Context, workload *platformv1.Workload, podTemplate corev1.PodTemplateSpec) (*appsv1.Deployment, *appsv1.Deployment, []string, error) {
+	log := log.
+		FromContext(ctx)
+
+	// a blue/green workload runs no canary
+	workload.Status.Canary = nil
+
+	hash, err := specHash(podTemplate)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	live := map[platformv1.DeploymentColor]*appsv1.Deployment{}
+	for _, color := range []platformv1.DeploymentColor{platformv1.DeploymentColorBlue, platformv1.DeploymentColorGreen} {
+		deployment := &appsv1.Deployment{}
+		key := types.NamespacedName{Namespace: workload.Namespace, Name: colorName(*workload, color)}
+		if err := r.Get(ctx, key, deployment); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return nil, nil, nil, err
+			}
+			continue
+		}
+		live[color] = deployment
+	}
+
+	_, promote := workload.Annotations[promoteAnnotation]
+	_, rollback := workload.Annotations[rollbackAnnotation]
+	active := recordedActiveColor(*workload, live)
+	var promoted, rolledBack bool
+
+	// the annotation stays until the status is saved, do not switch back twice
+	rollbackHandled := rollback && workload.Status.BlueGreen != nil && workload.Status.BlueGreen.RollbackHandled
+
+	// switch back to the previous color, its spec becomes the preview again
+	if rollback && !rollbackHandled {
+		if previous := live[otherColor(active)]; previous != nil && previous.Annotations[templateHashAnnotation] != "" {
+			active = otherColor(active)
+			rolledBack = true
+		} else {
+			log.Info("ignoring rollback of workload without a previous color")
+		}
+	}
+
+	activeLive, otherLive := live[active], live[otherColor(active)]
+	preview := activeLive != nil && activeLive.Annotations[templateHashAnnotation] != hash
+
+	// switch to the preview once it is rolled out
+	if promote && !rolledBack && preview {
+		if otherLive != nil && otherLive.Annotations[templateHashAnnotation] == hash && deploymentRolledOut(otherLive) {
+			active = otherColor(active)
+			activeLive, otherLive = otherLive, activeLive
+			preview = false
+			promoted = true
+		} else {
+			// keep the annotation until the preview can be promoted
+			promote = false
+		}
+	}
+
+	var handled []string
+	if promote {
+		handled = append(handled, promoteAnnotation)
+	}
+	if rollback {
+		handled = append(handled, rollbackAnnotation)
+	}
+
+	if promoted {
+		r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonPromoted, "Promoted %s to active", active)
+	}
+	if rolledBack {
+		r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonRolledBack, "Rolled back to %s", active)
+	}
+
+	status := &platformv1.BlueGreenStatus{ActiveColor: active, RollbackHandled: rollback}
+	workload.Status.BlueGreen = status
+
+	// the replicas of an autoscaled workload are owned by the HorizontalPodAutoscaler,
+	// the other color runs as many replicas as the active one
+	activeReplicas, replicas := workload.Spec.Replicas, workload.Spec.Replicas
+	if workload.Spec.Autoscaling != nil {
+		activeReplicas, replicas = nil, nil
+		if activeLive != nil {
+			replicas = activeLive.Spec.Replicas
+		}
+	}
+	previousReplicas := replicas
+	if spec := workload.Spec.Rollout.BlueGreen; spec != nil && spec.PreviousReplicas != nil {
+		previousReplicas = spec.PreviousReplicas
+	}
+
+	if !preview {
+		status.Message = fmt.Sprintf("Deployment (%s) is active", colorName(*workload, active))
+
+		activeDeployment, err := r.desiredColorDeployment(*workload, active, podTemplate, hash, activeReplicas)
+		if err != nil || otherLive == nil {
+			return activeDeployment, nil, handled, err
+		}
+		activeDeployment.Annotations[activeAnnotation] = "true"
+
+		// keep the previous color at its pod template for a rollback
+		previousDeployment, err := r.desiredColorDeployment(*workload, otherColor(active), otherLive.Spec.Template,
+			otherLive.Annotations[templateHashAnnotation], previousReplicas)
+		return activeDeployment, previousDeployment, handled, err
+	}
+
+	// the active color keeps its pod template until the preview is promoted
+	activeDeployment, err := r.desiredColorDeployment(*workload, active, activeLive.Spec.Template,
+		activeLive.Annotations[templateHashAnnotation], activeReplicas)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	activeDeployment.Annotations[activeAnnotation] = "true"
+
+	previewDeployment, err := r.desiredColorDeployment(*workload, otherColor(active), podTemplate, hash, replicas)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	status.PreviewColor = otherColor(active)
+	if otherLive != nil && otherLive.Annotations[templateHashAnnotation] == hash && deploymentRolledOut(otherLive) {
+		status.Message = fmt.Sprintf("Preview (%s) is ready, annotate the workload with %s to promote it",
+			previewDeployment.Name, promoteAnnotation)
+	} else {
+		status.Message = fmt.Sprintf("Preview (%s) is rolling out", previewDeployment.Name)
+	}
+
+	return activeDeployment, previewDeployment, handled, nil
+}
+
+// retiredDeployment returns the Deployment of the workload from before it
+// switched to or from blue/green rollouts, kept at its pod template so that the
+// Service has endpoints until the Deployment replacing it is rolled out. It
+// returns nil when there is none, or once the replacement is rolled out.
+func (r *WorkloadReconciler) retiredDeployment(ctx context.Context, workload platformv1.Workload, replacement *appsv1.Deployment) (*appsv1.Deployment, error) {
+	live := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(replacement), live); client.IgnoreNotFound(err) != nil {
+		return nil, err
+	} else if err == nil && deploymentRolledOut(live) {
+		return nil, nil
+	}
+
+	if usesBlueGreen(workload) {
+		retired := &appsv1.Deployment{}
+		key := types.NamespacedName{Namespace: workload.Namespace, Name: workload.Name}
+		if err := r.Get(ctx, key, retired); err != nil || !metav1.IsControlledBy(retired, &workload) {
+			return nil, client.IgnoreNotFound(err)
+		}
+
+		deployment, err := r.desiredDeployment(workload, retired.Spec.Template)
+		return &deployment, err
+	}
+
+	// the active color is kept, the other one is no longer needed
+	colors := map[platformv1.DeploymentColor]*appsv1.Deployment{}
+	for _, color := range []platformv1.DeploymentColor{platformv1.DeploymentColorBlue, platformv1.DeploymentColorGreen} {
+		deployment := &appsv1.Deployment{}
+		key := types.NamespacedName{Namespace: workload.Namespace, Name: colorName(workload, color)}
+		if err := r.Get(ctx, key, deployment); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return nil, err
+			}
+			continue
+		}
+		if metav1.IsControlledBy(deployment, &workload) {
+			colors[color] = deployment
+		}
+	}
+	active := recordedActiveColor(workload, colors)
+	if colors[active] == nil {
+		active = otherColor(active)
+	}
+	retired := colors[active]
+	if retired == nil {
+		return nil, nil
+	}
+
+	return r.desiredColorDeployment(workload, active, retired.Spec.Template, retired.Annotations[templateHashAnnotation],
+		retired.Spec.Replicas)
+}
+
+// removeAnnotations removes the annotations from the workload.
+func (r *WorkloadReconciler) removeAnnotations(ctx context.Context, workload *platformv1.Workload, names ...string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	patch := client.MergeFrom(workload.DeepCopy())
+	for _, name := range names {
+		delete(workload.Annotations, name)
+	}
+
+	return r.Patch(ctx, workload, patch)
+}
+
+// desiredColorDeployment returns the Deployment of the given color running the
+// pod template.
+func (r *WorkloadReconciler) desiredColorDeployment(workload platformv1.Workload, color platformv1.DeploymentColor, podTemplate corev1.PodTemplateSpec, hash string, replicas *int32) (*appsv1.Deployment, error) {
+	selector := selectorLabels(workload)
+	selector[colorLabel] = string(color)
+
+	template := *podTemplate.DeepCopy()
+	if template.Labels == nil {
+		template.Labels = map[string]string{}
+	}
+	template.Labels[colorLabel] = string(color)
+
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        colorName(workload, color),
+			Namespace:   workload.Namespace,
+			Labels:      selectorLabels(workload),
+			Annotations: map[string]string{templateHashAnnotation: hash},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selector,
+			},
+			Template: template,
+		},
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, deployment, r.Scheme); err != nil {
+		return deployment, err
+	}
+
+	return deployment, nil
+}
+
+// desiredPreviewService returns the Service selecting the preview color of the
+// workload.
+func (r *WorkloadReconciler) desiredPreviewService(workload platformv1.Workload) (corev1.Service, error) {
+	service, err := r.desiredService(workload)
+	if err != nil {
+		return service, err
+	}
+
+	service.Name = workload.Name + previewSuffix
+	service.Spec.Selector = selectorLabels(workload)
+	service.Spec.Selector[colorLabel] = string(workload.Status.BlueGreen.PreviewColor)
+
+	// the preview is only reached from within the cluster
+	service.Spec.Type = corev1.ServiceTypeClusterIP
+	service.Spec.ClusterIP = ""
+	for i := range service.Spec.Ports {
+		service.Spec.Ports[i].NodePort = 0
+	}
+
+	return service, nil
+}
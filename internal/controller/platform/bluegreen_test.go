@@ -0,0 +1,244 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/client-go/tools/record"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+const (
+	blue  = platformv1.DeploymentColorBlue
+	green = platformv1.DeploymentColorGreen
+)
+
+// expectActive fails the test unless the active Deployment is of the color and
+// runs the image.
+func expectActive(t *testing.T, workload *platformv1.Workload, active *appsv1.Deployment, color platformv1.DeploymentColor, image string) {
+	t.Helper()
+
+	if active.Name != colorName(*workload, color) || containerImage(active.Spec.Template) != image {
+		t.Errorf("expected %s to be active at %s, got %s at %s", colorName(*workload, color), image,
+			active.Name, containerImage(active.Spec.Template))
+	}
+	if got := workload.Status.BlueGreen.ActiveColor; got != color {
+		t.Errorf("expected the active color %s in the status, got %s", color, got)
+	}
+}
+
+func TestBlueGreenRolloutPromote(t *testing.T) {
+	ctx := context.Background()
+	workload := blueGreenWorkload("app:v2")
+	workload.Status.BlueGreen = &platformv1.BlueGreenStatus{ActiveColor: blue}
+	workload.Annotations = map[string]string{promoteAnnotation: ""}
+
+	preview := colorDeployment(t, workload, green, "app:v2", false)
+	preview.Status.AvailableReplicas = 0
+	r := newTestReconciler(colorDeployment(t, workload, blue, "app:v1", true), preview)
+
+	// a promotion before the preview is ready waits for it
+	active, other, handled, err := r.blueGreenRollout(ctx, workload, testPodTemplate("app:v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectActive(t, workload, active, blue, "app:v1")
+	if other == nil || other.Name != "web-green" || workload.Status.BlueGreen.PreviewColor != green {
+		t.Errorf("expected web-green to be the preview, got %v", workload.Status.BlueGreen)
+	}
+	if len(handled) != 0 {
+		t.Errorf("expected the promote annotation to be kept, got %v handled", handled)
+	}
+
+	if err := r.Update(ctx, rolledOut(preview)); err != nil {
+		t.Fatal(err)
+	}
+	active, other, handled, err = r.blueGreenRollout(ctx, workload, testPodTemplate("app:v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectActive(t, workload, active, green, "app:v2")
+	if active.Annotations[activeAnnotation] != "true" || other == nil || other.Annotations[activeAnnotation] != "" {
+		t.Error("expected the promoted color to be marked active")
+	}
+	if len(handled) != 1 || handled[0] != promoteAnnotation {
+		t.Errorf("expected the promote annotation to be handled, got %v", handled)
+	}
+	expectEvent(t, r, reasonPromoted)
+}
+
+func TestBlueGreenRolloutRollback(t *testing.T) {
+	ctx := context.Background()
+	workload := blueGreenWorkload("app:v2")
+	workload.Status.BlueGreen = &platformv1.BlueGreenStatus{ActiveColor: blue}
+	workload.Annotations = map[string]string{rollbackAnnotation: ""}
+
+	r := newTestReconciler(colorDeployment(t, workload, blue, "app:v2", true),
+		colorDeployment(t, workload, green, "app:v1", false))
+
+	active, other, handled, err := r.blueGreenRollout(ctx, workload, testPodTemplate("app:v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectActive(t, workload, active, green, "app:v1")
+	// the spec of the workload becomes the preview again
+	if other == nil || containerImage(other.Spec.Template) != "app:v2" || workload.Status.BlueGreen.PreviewColor != blue {
+		t.Errorf("expected web-blue to be the preview at app:v2, got %v", workload.Status.BlueGreen)
+	}
+	if len(handled) != 1 || handled[0] != rollbackAnnotation || !workload.Status.BlueGreen.RollbackHandled {
+		t.Errorf("expected the rollback to be handled, got %v", handled)
+	}
+	expectEvent(t, r, reasonRolledBack)
+
+	// the annotation outlives the saved status, the rollback is not repeated
+	for _, deployment := range []*appsv1.Deployment{active, other} {
+		if err := r.Update(ctx, rolledOut(deployment)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	active, _, handled, err = r.blueGreenRollout(ctx, workload, testPodTemplate("app:v2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectActive(t, workload, active, green, "app:v1")
+	if len(handled) != 1 || handled[0] != rollbackAnnotation {
+		t.Errorf("expected the rollback annotation to be removed, got %v", handled)
+	}
+	if events := r.Recorder.(*record.FakeRecorder).Events; len(events) != 0 {
+		t.Errorf("expected a single rollback, got %s", <-events)
+	}
+}
+
+func TestBlueGreenRolloutRollbackWithoutPreviousColor(t *testing.T) {
+	ctx := context.Background()
+	workload := blueGreenWorkload("app:v1")
+	workload.Status.BlueGreen = &platformv1.BlueGreenStatus{ActiveColor: blue}
+	workload.Annotations = map[string]string{rollbackAnnotation: ""}
+	r := newTestReconciler(colorDeployment(t, workload, blue, "app:v1", false))
+
+	active, other, handled, err := r.blueGreenRollout(ctx, workload, testPodTemplate("app:v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectActive(t, workload, active, blue, "app:v1")
+	if other != nil {
+		t.Errorf("expected no other color, got %s", other.Name)
+	}
+	if len(handled) != 1 || handled[0] != rollbackAnnotation {
+		t.Errorf("expected the rollback annotation to be removed, got %v", handled)
+	}
+	if events := r.Recorder.(*record.FakeRecorder).Events; len(events) != 0 {
+		t.Errorf("expected no rollback, got %s", <-events)
+	}
+}
+
+func TestBlueGreenRolloutLostStatus(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name       string
+		image      string
+		annotation string
+		green      string
+		wantActive platformv1.DeploymentColor
+		wantImage  string
+	}{
+		{name: "active color of the Deployments", image: "app:v2", wantActive: green, wantImage: "app:v2"},
+		{name: "rollback", image: "app:v2", annotation: rollbackAnnotation, wantActive: blue, wantImage: "app:v1"},
+		{name: "promote", image: "app:v3", annotation: promoteAnnotation, green: "app:v3", wantActive: blue, wantImage: "app:v3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the status is gone, green is marked active
+			workload := blueGreenWorkload(tt.image)
+			if tt.annotation != "" {
+				workload.Annotations = map[string]string{tt.annotation: ""}
+			}
+			previous := colorDeployment(t, workload, blue, "app:v1", false)
+			if tt.annotation == promoteAnnotation {
+				previous = colorDeployment(t, workload, blue, "app:v3", false)
+			}
+			r := newTestReconciler(colorDeployment(t, workload, green, "app:v2", true), previous)
+
+			active, _, _, err := r.blueGreenRollout(ctx, workload, testPodTemplate(tt.image))
+			if err != nil {
+				t.Fatal(err)
+			}
+			expectActive(t, workload, active, tt.wantActive, tt.wantImage)
+		})
+	}
+}
+
+func TestRetiredDeployment(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("switch to blue/green", func(t *testing.T) {
+		workload := blueGreenWorkload("app:v2")
+		plain, err := newTestReconciler().desiredDeployment(*workload, testPodTemplate("app:v1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := newTestReconciler(rolledOut(&plain))
+
+		active := colorDeployment(t, workload, blue, "app:v2", false)
+		retired, err := r.retiredDeployment(ctx, *workload, active)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retired == nil || retired.Name != "web" || containerImage(retired.Spec.Template) != "app:v1" {
+			t.Fatalf("expected web to be kept at app:v1 until web-blue is rolled out, got %v", retired)
+		}
+
+		if err := r.Create(ctx, active); err != nil {
+			t.Fatal(err)
+		}
+		if retired, err := r.retiredDeployment(ctx, *workload, active); err != nil || retired != nil {
+			t.Errorf("expected web to be retired once web-blue is rolled out, got %v (%v)", retired, err)
+		}
+	})
+
+	t.Run("switch from blue/green", func(t *testing.T) {
+		workload := blueGreenWorkload("app:v2")
+		r := newTestReconciler(colorDeployment(t, workload, blue, "app:v1", false),
+			colorDeployment(t, workload, green, "app:v2", true))
+
+		workload.Spec.Rollout = nil
+		deployment, err := r.desiredDeployment(*workload, testPodTemplate("app:v2"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		retired, err := r.retiredDeployment(ctx, *workload, &deployment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if retired == nil || retired.Name != "web-green" {
+			t.Fatalf("expected the active web-green to be kept until web is rolled out, got %v", retired)
+		}
+
+		if err := r.Create(ctx, rolledOut(&deployment)); err != nil {
+			t.Fatal(err)
+		}
+		if retired, err := r.retiredDeployment(ctx, *workload, &deployment); err != nil || retired != nil {
+			t.Errorf("expected web-green to be retired once web is rolled out, got %v (%v)", retired, err)
+		}
+	})
+}
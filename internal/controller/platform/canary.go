@@ -0,0 +1,402 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+const (
+	// trackLabel tells the stable pods of a workload with canary rollouts
+	// apart from its canary pods
+	trackLabel   = "platform.mydev.org/track"
+	trackStable  = "stable"
+	trackCanary  = "canary"
+	canarySuffix = "-canary"
+
+	// canaryPollInterval is how often a canary without analysis is checked
+	canaryPollInterval = 15 * time.Second
+)
+
+// usesCanary reports whether image changes of the workload are rolled out by
+// a canary.
+func usesCanary(workload platformv1.Workload) bool {
+	rollout := workload.Spec.Rollout
+	return rollout != nil && rollout.Strategy == platformv1.RolloutStrategyCanary && rollout.Canary != nil &&
+		!isBatch(workload) && workload.Spec.Storage == nil
+}
+
+// canaryActive reports whether a canary of the workload is running.
+func canaryActive(workload platformv1.Workload) bool {
+	return usesCanary(workload) && workload.Status.Canary != nil &&
+		workload.Status.Canary.Phase == platformv1.CanaryPhaseProgressing
+}
+
+// weightedRouting reports whether the traffic of a canary is weighted by the
+// HTTPRoute of the workload rather than by the replicas of the canary.
+func (r *WorkloadReconciler) weightedRouting(workload platformv1.Workload) bool {
+	return usesCanary(workload) && workload.Spec.Service != nil && workload.Spec.Ingress != nil &&
+		r.Config.Routing.Mode == configv1alpha1.RoutingModeGatewayAPI
+}
+
+// containerImage returns the image of the workload container of the pod template.
+func containerImage(template corev1.PodTemplateSpec) string {
+	for _, container := range template.Spec.Containers {
+		if container.Name == workloadContainerName {
+			return container.Image
+		}
+	}
+
+	return ""
+}
+
+// setContainerImage sets the image of the workload container of the pod template.
+func setContainerImage(template *corev1.PodTemplateSpec, image string) {
+	for i := range template.Spec.Containers {
+		if template.Spec.Containers[i].Name == workloadContainerName {
+			template.Spec.Containers[i].Image = image
+		}
+	}
+}
+
+// canaryRollout advances the canary rollout of the workload. Until the canary
+// is promoted, the Deployment of the workload keeps running its stable image.
+// It returns the canary Deployment to apply, if any, and when to check the
+// canary again.
+func (r *WorkloadReconciler) canaryRollout(ctx context.Context, workload *platformv1.Workload, deployment *appsv1.Deployment) (*appsv1.Deployment, time.Duration, error) {
+	log := log.
+		FromContext(ctx)
+
+	if !usesCanary(*workload) {
+		workload.Status.Canary = nil
+		return nil, 0, nil
+	}
+
+	// the first rollout of a workload has no stable image to compare to
+	stable := &appsv1.Deployment{}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(deployment), stable); err != nil {
+		return nil, 0, client.IgnoreNotFound(err)
+	}
+
+	image := workload.Spec.Container.Image
+	stableImage := containerImage(stable.Spec.Template)
+	status := workload.Status.Canary
+	if stableImage == "" || stableImage == image {
+		// the image was reverted before the canary was promoted
+		if status != nil && status.Phase != platformv1.CanaryPhasePromoted {
+			workload.Status.Canary = nil
+		}
+		return nil, 0, nil
+	}
+
+	if status == nil || status.Image != image {
+		status = &platformv1.CanaryStatus{
+			Phase:       platformv1.CanaryPhaseProgressing,
+			Image:       image,
+			StableImage: stableImage,
+		}
+		workload.Status.Canary = status
+		r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonCanaryStarted, "Started canary of image %s", image)
+	}
+
+	switch status.Phase {
+	case platformv1.CanaryPhasePromoted:
+		return nil, 0, nil
+	case platformv1.CanaryPhaseAborted:
+		setContainerImage(&deployment.Spec.Template, status.StableImage)
+		return nil, 0, nil
+	}
+
+	canarySpec := workload.Spec.Rollout.Canary
+	interval := canaryPollInterval
+	if canarySpec.Analysis != nil {
+		interval = time.Duration(canarySpec.Analysis.IntervalSeconds) * time.Second
+	}
+
+	total := canaryTotal(deployment, stable)
+	for int(status.Step) < len(canarySpec.Steps) {
+		step := canarySpec.Steps[status.Step]
+
+		canary, err := r.desiredCanaryDeployment(*workload, deployment, canaryReplicas(total, step.Weight))
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// the stable Deployment gives up replicas once the canary of a step is ready
+		heldReplicas := r.stableReplicas(*workload, deployment, total, status.Weight)
+
+		// wait for the canary to be ready at the replicas of this step
+		live := &appsv1.Deployment{}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(canary), live); err != nil {
+			if client.IgnoreNotFound(err) != nil {
+				return nil, 0, err
+			}
+			status.Message = fmt.Sprintf("Creating canary (%s)", canary.Name)
+			keepStable(deployment, stableImage, heldReplicas)
+			return canary, interval, nil
+		}
+
+		if cond := deploymentCondition(live, appsv1.DeploymentProgressing); cond != nil &&
+			cond.Reason == reasonProgressDeadlineExceeded {
+			r.abortCanary(workload, deployment, fmt.Sprintf("Canary (%s) exceeded its progress deadline: %s", live.Name, cond.Message))
+			return nil, 0, nil
+		}
+
+		if containerImage(live.Spec.Template) != image || desiredReplicas(live) != desiredReplicas(canary) ||
+			!deploymentRolledOut(live) {
+			status.Message = fmt.Sprintf("Canary (%s) is rolling out: %d of %d replicas are available",
+				live.Name, live.Status.AvailableReplicas, desiredReplicas(canary))
+			keepStable(deployment, stableImage, heldReplicas)
+			return canary, interval, nil
+		}
+
+		// shift the traffic of this step to the ready canary
+		if status.Weight != step.Weight || status.StepStartTime == nil {
+			now := metav1.Now()
+			status.Weight = step.Weight
+			status.StepStartTime = &now
+			r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonCanaryProgressed,
+				"Shifted %d%% of traffic to canary image %s", step.Weight, image)
+		}
+		stableReplicas := r.stableReplicas(*workload, deployment, total, status.Weight)
+
+		if analysis := canarySpec.Analysis; analysis != nil {
+			// analyse once per interval, and at least once at every step
+			last := status.LastAnalysisTime
+			if last == nil || last.Before(status.StepStartTime) || time.Since(last.Time) >= interval {
+				now := metav1.Now()
+				status.LastAnalysisTime = &now
+				status.ErrorRate = ""
+
+				errorRate, err := r.canaryErrorRate(ctx, *workload, analysis)
+				if err != nil {
+					// the canary is neither promoted nor aborted without a result
+					if errors.Is(err, errNoData) {
+						status.Message = fmt.Sprintf("Analysis of canary (%s) is inconclusive: %s", canary.Name, err)
+					} else {
+						log.Error(err, "Failed to analyse canary", "canary", canary.Name)
+						status.Message = fmt.Sprintf("Failed to analyse canary (%s): %s", canary.Name, err)
+					}
+					keepStable(deployment, stableImage, stableReplicas)
+					return canary, interval, nil
+				}
+
+				status.ErrorRate = strconv.FormatFloat(errorRate, 'f', -1, 64)
+				if errorRate > analysis.MaxErrorRate.AsApproximateFloat64() {
+					r.abortCanary(workload, deployment, fmt.Sprintf("Canary (%s) has an error rate of %s, more than %s",
+						canary.Name, status.ErrorRate, analysis.MaxErrorRate.String()))
+					return nil, 0, nil
+				}
+			} else if status.ErrorRate == "" {
+				// the last analysis of this step was inconclusive
+				keepStable(deployment, stableImage, stableReplicas)
+				return canary, interval - time.Since(last.Time), nil
+			}
+		}
+
+		pause := time.Duration(step.PauseSeconds)*time.Second - time.Since(status.StepStartTime.Time)
+		if pause > 0 {
+			status.Message = fmt.Sprintf("Canary (%s) is at step %d of %d with %d%% of traffic",
+				canary.Name, status.Step+1, len(canarySpec.Steps), status.Weight)
+			keepStable(deployment, stableImage, stableReplicas)
+			if pause < interval {
+				interval = pause
+			}
+			return canary, interval, nil
+		}
+
+		status.Step++
+	}
+
+	// all steps passed, roll out the canary image to the Deployment
+	status.Phase = platformv1.CanaryPhasePromoted
+	status.Weight = 0
+	status.Message = fmt.Sprintf("Canary image %s was promoted", image)
+	r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonCanaryPromoted, "Promoted canary image %s", image)
+
+	return nil, 0, nil
+}
+
+// abortCanary ends the canary of the workload and keeps the Deployment at the
+// stable image.
+func (r *WorkloadReconciler) abortCanary(workload *platformv1.Workload, deployment *appsv1.Deployment, message string) {
+	status := workload.Status.Canary
+	status.Phase = platformv1.CanaryPhaseAborted
+	status.Weight = 0
+	status.Message = message
+	setContainerImage(&deployment.Spec.Template, status.StableImage)
+
+	r.Recorder.Eventf(workload, corev1.EventTypeWarning, reasonCanaryAborted, "Aborted canary of image %s: %s", status.Image, message)
+}
+
+// keepStable keeps the Deployment of a workload with a running canary at the
+// stable image and at the replicas left to it by the canary.
+func keepStable(deployment *appsv1.Deployment, image string, replicas *int32) {
+	setContainerImage(&deployment.Spec.Template, image)
+	deployment.Spec.Replicas = replicas
+}
+
+// canaryTotal returns the replicas shared by the stable and the canary pods of
+// the workload, the replicas of the stable Deployment when they are owned by a
+// HorizontalPodAutoscaler.
+func canaryTotal(deployment, stable *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas != nil {
+		return *deployment.Spec.Replicas
+	}
+
+	return desiredReplicas(stable)
+}
+
+// canaryReplicas returns the replicas of the canary at the given weight, at
+// least one.
+func canaryReplicas(total, weight int32) int32 {
+	replicas := (total*weight + 99) / 100
+	if replicas < 1 {
+		replicas = 1
+	}
+
+	return replicas
+}
+
+// stableReplicas returns the replicas of the stable Deployment while the canary
+// serves the given weight. Without weighted routing the Service balances over
+// the stable and the canary pods, so the stable Deployment gives up the
+// replicas of the canary, keeping at least one. Weighted routing and autoscaled
+// workloads keep all stable replicas.
+func (r *WorkloadReconciler) stableReplicas(workload platformv1.Workload, deployment *appsv1.Deployment, total, weight int32) *int32 {
+	if weight == 0 || deployment.Spec.Replicas == nil || r.weightedRouting(workload) {
+		return deployment.Spec.Replicas
+	}
+
+	replicas := total - canaryReplicas(total, weight)
+	if replicas < 1 {
+		replicas = 1
+	}
+
+	return &replicas
+}
+
+// desiredCanaryDeployment returns the canary Deployment of the workload, running
+// the pod template of the deployment with the given replicas.
+func (r *WorkloadReconciler) desiredCanaryDeployment(workload platformv1.Workload, deployment *appsv1.Deployment, replicas int32) (*appsv1.Deployment, error) {
+	selector := selectorLabels(workload)
+	selector[trackLabel] = trackCanary
+
+	template := *deployment.Spec.Template.DeepCopy()
+	template.Labels[trackLabel] = trackCanary
+
+	canary := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workload.Name + canarySuffix,
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selector,
+			},
+			Template: template,
+		},
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, canary, r.Scheme); err != nil {
+		return canary, err
+	}
+
+	return canary, nil
+}
+
+// desiredCanaryService returns the Service selecting the canary pods of the
+// workload, the backend of the canary weight of its HTTPRoute.
+func (r *WorkloadReconciler) desiredCanaryService(workload platformv1.Workload) (corev1.Service, error) {
+	service, err := r.desiredService(workload)
+	if err != nil {
+		return service, err
+	}
+
+	service.Name = workload.Name + canarySuffix
+	service.Spec.Selector = selectorLabels(workload)
+	service.Spec.Selector[trackLabel] = trackCanary
+
+	// the canary is only reached through the HTTPRoute
+	service.Spec.Type = corev1.ServiceTypeClusterIP
+	service.Spec.ClusterIP = ""
+	for i := range service.Spec.Ports {
+		service.Spec.Ports[i].NodePort = 0
+	}
+
+	return service, nil
+}
+
+// stableTracked reports whether the Service of the workload can select its
+// stable pods by their track: the Deployment of the workload rolled out the
+// track label to all of its pods, or the Service already selects them by it.
+func (r *WorkloadReconciler) stableTracked(ctx context.Context, workload platformv1.Workload) (bool, error) {
+	key := client.ObjectKey{Namespace: workload.Namespace, Name: workload.Name}
+	stable := &appsv1.Deployment{}
+	if err := r.Get(ctx, key, stable); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	if stable.Spec.Template.Labels[trackLabel] != trackStable {
+		return false, nil
+	}
+	if deploymentRolledOut(stable) {
+		return true, nil
+	}
+
+	// later rollouts of the labeled template keep the selector
+	service := &corev1.Service{}
+	if err := r.Get(ctx, key, service); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return service.Spec.Selector[trackLabel] == trackStable, nil
+}
+
+// canaryErrorRate evaluates the analysis query of the canary of the workload.
+func (r *WorkloadReconciler) canaryErrorRate(ctx context.Context, workload platformv1.Workload, analysis *platformv1.CanaryAnalysis) (float64, error) {
+	tmpl, err := template.New("analysis").Option("missingkey=error").Parse(analysis.Query)
+	if err != nil {
+		return 0, fmt.Errorf("invalid analysis query: %w", err)
+	}
+
+	var query strings.Builder
+	data := struct{ Namespace, Name, Canary string }{workload.Namespace, workload.Name, workload.Name + canarySuffix}
+	if err := tmpl.Execute(&query, data); err != nil {
+		return 0, fmt.Errorf("invalid analysis query: %w", err)
+	}
+
+	return r.queryPrometheus(ctx, query.String())
+}
@@ -0,0 +1,238 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+func errorRateAnalysis() *platformv1.CanaryAnalysis {
+	return &platformv1.CanaryAnalysis{
+		Query:           `errors{deployment="{{.Canary}}"}`,
+		MaxErrorRate:    resource.MustParse("0.05"),
+		IntervalSeconds: 30,
+	}
+}
+
+func TestCanaryReplicas(t *testing.T) {
+	r := newTestReconciler()
+	workload := *canaryWorkload("app:v2", nil)
+
+	tests := []struct {
+		name       string
+		replicas   *int32
+		weight     int32
+		wantCanary int32
+		wantStable *int32
+	}{
+		{name: "no traffic", replicas: pointer.Int32(10), weight: 0, wantCanary: 1, wantStable: pointer.Int32(10)},
+		{name: "share of the replicas", replicas: pointer.Int32(10), weight: 20, wantCanary: 2, wantStable: pointer.Int32(8)},
+		{name: "rounded up to a pod", replicas: pointer.Int32(10), weight: 5, wantCanary: 1, wantStable: pointer.Int32(9)},
+		{name: "stable keeps a pod", replicas: pointer.Int32(2), weight: 90, wantCanary: 2, wantStable: pointer.Int32(1)},
+		{name: "autoscaled", replicas: nil, weight: 50, wantCanary: 2, wantStable: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deployment := testDeployment("web", "app:v2", 0)
+			deployment.Spec.Replicas = tt.replicas
+			stable := testDeployment("web", "app:v1", 4)
+
+			total := canaryTotal(deployment, stable)
+			if got := canaryReplicas(total, tt.weight); got != tt.wantCanary {
+				t.Errorf("canaryReplicas() = %d, want %d", got, tt.wantCanary)
+			}
+			got := r.stableReplicas(workload, deployment, total, tt.weight)
+			if (got == nil) != (tt.wantStable == nil) || (got != nil && *got != *tt.wantStable) {
+				t.Errorf("stableReplicas() = %v, want %v", got, tt.wantStable)
+			}
+		})
+	}
+}
+
+func TestCanaryRolloutPromotes(t *testing.T) {
+	ctx := context.Background()
+	queries := 0
+	server := newPrometheus(t, vectorResponse("0.01"), &queries)
+
+	r := newTestReconciler(testDeployment("web", "app:v1", 4))
+	r.Config.Rollouts.PrometheusURL = server.URL
+	workload := canaryWorkload("app:v2", errorRateAnalysis())
+
+	// the canary is created while the stable Deployment keeps its image and replicas
+	deployment := testDeployment("web", "app:v2", 4)
+	canary, _, err := r.canaryRollout(ctx, workload, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canary == nil || desiredReplicas(canary) != 2 {
+		t.Fatalf("expected a canary with 2 replicas, got %v", canary)
+	}
+	if containerImage(deployment.Spec.Template) != "app:v1" || desiredReplicas(deployment) != 4 {
+		t.Errorf("expected the stable Deployment at app:v1 with 4 replicas, got %s with %d",
+			containerImage(deployment.Spec.Template), desiredReplicas(deployment))
+	}
+	if status := workload.Status.Canary; status == nil || status.Phase != platformv1.CanaryPhaseProgressing {
+		t.Fatalf("expected a progressing canary, got %v", status)
+	}
+
+	// the ready canary passes its analysis and is promoted
+	if err := r.Create(ctx, rolledOut(canary)); err != nil {
+		t.Fatal(err)
+	}
+	deployment = testDeployment("web", "app:v2", 4)
+	canary, _, err = r.canaryRollout(ctx, workload, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canary != nil {
+		t.Error("expected no canary after the promotion")
+	}
+	if status := workload.Status.Canary; status.Phase != platformv1.CanaryPhasePromoted || status.ErrorRate != "0.01" {
+		t.Errorf("expected the canary to be promoted with an error rate of 0.01, got %v", status)
+	}
+	if containerImage(deployment.Spec.Template) != "app:v2" {
+		t.Errorf("expected the Deployment to roll out app:v2, got %s", containerImage(deployment.Spec.Template))
+	}
+	if queries != 1 {
+		t.Errorf("expected 1 analysis query, got %d", queries)
+	}
+}
+
+func TestCanaryRolloutAborts(t *testing.T) {
+	ctx := context.Background()
+	server := newPrometheus(t, vectorResponse("0.5"), nil)
+
+	workload := canaryWorkload("app:v2", errorRateAnalysis())
+	canary, err := newTestReconciler().desiredCanaryDeployment(*workload, testDeployment("web", "app:v2", 4), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestReconciler(testDeployment("web", "app:v1", 4), rolledOut(canary))
+	r.Config.Rollouts.PrometheusURL = server.URL
+
+	deployment := testDeployment("web", "app:v2", 4)
+	if _, _, err := r.canaryRollout(ctx, workload, deployment); err != nil {
+		t.Fatal(err)
+	}
+	if status := workload.Status.Canary; status.Phase != platformv1.CanaryPhaseAborted {
+		t.Fatalf("expected the canary to be aborted, got %v", status)
+	}
+	if containerImage(deployment.Spec.Template) != "app:v1" || desiredReplicas(deployment) != 4 {
+		t.Errorf("expected the Deployment back at app:v1 with 4 replicas, got %s with %d",
+			containerImage(deployment.Spec.Template), desiredReplicas(deployment))
+	}
+
+	// an aborted canary stays aborted until the image changes
+	deployment = testDeployment("web", "app:v2", 4)
+	canary, _, err = r.canaryRollout(ctx, workload, deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if canary != nil || containerImage(deployment.Spec.Template) != "app:v1" {
+		t.Error("expected the aborted canary to keep the stable image")
+	}
+}
+
+func TestCanaryRolloutHoldsWithoutData(t *testing.T) {
+	ctx := context.Background()
+	queries := 0
+	server := newPrometheus(t, vectorResponse(), &queries)
+
+	workload := canaryWorkload("app:v2", errorRateAnalysis())
+	canary, err := newTestReconciler().desiredCanaryDeployment(*workload, testDeployment("web", "app:v2", 4), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newTestReconciler(testDeployment("web", "app:v1", 4), rolledOut(canary))
+	r.Config.Rollouts.PrometheusURL = server.URL
+
+	for i := 0; i < 2; i++ {
+		deployment := testDeployment("web", "app:v2", 4)
+		canary, requeueAfter, err := r.canaryRollout(ctx, workload, deployment)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		status := workload.Status.Canary
+		if canary == nil || status.Phase != platformv1.CanaryPhaseProgressing || status.Step != 0 {
+			t.Fatalf("expected the canary to hold at its step, got %v", status)
+		}
+		if !strings.Contains(status.Message, "inconclusive") {
+			t.Errorf("expected an inconclusive analysis, got %q", status.Message)
+		}
+		if requeueAfter <= 0 {
+			t.Errorf("expected a requeue, got %s", requeueAfter)
+		}
+		// the canary serves half of the replicas
+		if containerImage(deployment.Spec.Template) != "app:v1" || desiredReplicas(deployment) != 2 {
+			t.Errorf("expected the stable Deployment at app:v1 with 2 replicas, got %s with %d",
+				containerImage(deployment.Spec.Template), desiredReplicas(deployment))
+		}
+	}
+
+	// the second reconcile is within the analysis interval
+	if queries != 1 {
+		t.Errorf("expected 1 analysis query per interval, got %d", queries)
+	}
+}
+
+func TestStableTracked(t *testing.T) {
+	ctx := context.Background()
+	workload := *canaryWorkload("app:v1", nil)
+
+	labeled := testDeployment("web", "app:v1", 4)
+	labeled.Spec.Template.Labels[trackLabel] = trackStable
+	trackedService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{workloadLabel: "web", trackLabel: trackStable}},
+	}
+
+	tests := []struct {
+		name string
+		objs []client.Object
+		want bool
+	}{
+		{name: "no Deployment"},
+		{name: "pods without track", objs: []client.Object{rolledOut(testDeployment("web", "app:v1", 4))}},
+		{name: "track label rolling out", objs: []client.Object{labeled}},
+		{name: "track label rolled out", objs: []client.Object{rolledOut(labeled)}, want: true},
+		{name: "later rollout of the labeled pods", objs: []client.Object{labeled, trackedService}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTestReconciler(tt.objs...).stableTracked(ctx, workload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("stableTracked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
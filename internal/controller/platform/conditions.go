@@ -0,0 +1,343 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// Definitions to manage status conditions. The aggregate conditions follow the
+// kstatus conventions: Ready is a normal-true condition, Progressing and
+// Degraded are abnormal-true conditions that are False once the Workload is
+// rolled out.
+const (
+	// typeReadyWorkload represents the status of the entire Workload reconciliation
+	typeReadyWorkload = "Ready"
+	// typeProgressingWorkload is True while the objects of the Workload are rolling out
+	typeProgressingWorkload = "Progressing"
+	// typeDegradedWorkload is True when the Workload can not be reconciled, and
+	// is used when the custom resource is deleted and the finalizer operations are performed.
+	typeDegradedWorkload = "Degraded"
+	// typeAvailableWorkload represents the availability of the pods of the Workload
+	typeAvailableWorkload = "Available"
+	// typePausedWorkload is True while the objects of the Workload are not reconciled
+	typePausedWorkload = "Paused"
+	// typeDriftedWorkload is True when objects of the Workload were changed by others
+	typeDriftedWorkload = "Drifted"
+
+	// resourceConditionSuffix is appended to the kind of an applied object to
+	// make the type of its condition, e.g. DeploymentReady
+	resourceConditionSuffix = "Ready"
+)
+
+// Reasons of the status conditions
+const (
+	reasonReconciling              = "Reconciling"
+	reasonSucceeded                = "Succeeded"
+	reasonApplied                  = "Applied"
+	reasonRollingOut               = "RollingOut"
+	reasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+	reasonMinimumReplicasAvailable = "MinimumReplicasAvailable"
+	reasonMinimumReplicasUnavail   = "MinimumReplicasUnavailable"
+	reasonFinalizing               = "Finalizing"
+	reasonRunning                  = "Running"
+	reasonJobFailed                = "JobFailed"
+	reasonScheduled                = "Scheduled"
+	reasonCanaryProgressing        = "CanaryProgressing"
+	reasonAwaitingPromotion        = "AwaitingPromotion"
+	reasonReconcileDisabled        = "ReconcileDisabled"
+	reasonNoDrift                  = "NoDrift"
+	reasonDisruptionsBlocked       = "DisruptionsBlocked"
+)
+
+// resourceConditionType returns the condition type for objects of the given kind.
+func resourceConditionType(kind string) string {
+	return kind + resourceConditionSuffix
+}
+
+// isResourceCondition reports whether the condition type is kept for a kind of
+// applied object rather than for the Workload as a whole.
+func isResourceCondition(conditionType string) bool {
+	return conditionType != typeReadyWorkload && strings.HasSuffix(conditionType, resourceConditionSuffix)
+}
+
+// setCondition sets a status condition of the workload for its current generation.
+func setCondition(workload *platformv1.Workload, conditionType string, status metav1.ConditionStatus, reason, message string) {
+	meta.SetStatusCondition(&workload.Status.Conditions, metav1.Condition{Type: conditionType,
+		Status: status, Reason: reason, Message: message,
+		ObservedGeneration: workload.Generation,
+	})
+	workload.Status.Phase = workloadPhase(workload)
+}
+
+// workloadPhase summarizes the conditions of the workload.
+func workloadPhase(workload *platformv1.Workload) platformv1.WorkloadPhase {
+	conditions := workload.Status.Conditions
+
+	switch {
+	case !workload.GetDeletionTimestamp().IsZero():
+		return platformv1.WorkloadPhaseTerminating
+	case meta.IsStatusConditionTrue(conditions, typeDegradedWorkload):
+		return platformv1.WorkloadPhaseDegraded
+	case meta.IsStatusConditionTrue(conditions, typeReadyWorkload):
+		return platformv1.WorkloadPhaseReady
+	case meta.IsStatusConditionTrue(conditions, typeProgressingWorkload):
+		return platformv1.WorkloadPhaseProgressing
+	default:
+		return platformv1.WorkloadPhasePending
+	}
+}
+
+// setFailed marks the workload as not ready and degraded for the given reason.
+func setFailed(workload *platformv1.Workload, reason, message string) {
+	workload.Status.ObservedGeneration = workload.Generation
+	setCondition(workload, typeReadyWorkload, metav1.ConditionFalse, reason, message)
+	setCondition(workload, typeProgressingWorkload, metav1.ConditionFalse, reason, message)
+	setCondition(workload, typeDegradedWorkload, metav1.ConditionTrue, reason, message)
+}
+
+// pruneResourceConditions removes the resource conditions of kinds that are no
+// longer applied for the workload.
+func pruneResourceConditions(workload *platformv1.Workload, kinds map[string]bool) {
+	for _, condition := range append([]metav1.Condition{}, workload.Status.Conditions...) {
+		if !isResourceCondition(condition.Type) {
+			continue
+		}
+		if !kinds[strings.TrimSuffix(condition.Type, resourceConditionSuffix)] {
+			meta.RemoveStatusCondition(&workload.Status.Conditions, condition.Type)
+		}
+	}
+}
+
+// setDeploymentConditions sets the DeploymentReady and Available conditions from
+// the status of the deployment, and the aggregate conditions of the workload
+// from its rollout.
+func setDeploymentConditions(workload *platformv1.Workload, deployment *appsv1.Deployment) {
+	if cond := deploymentCondition(deployment, appsv1.DeploymentAvailable); cond != nil && cond.Status == corev1.ConditionTrue {
+		setCondition(workload, typeAvailableWorkload, metav1.ConditionTrue, reasonMinimumReplicasAvailable,
+			"Deployment has minimum availability")
+	} else {
+		setCondition(workload, typeAvailableWorkload, metav1.ConditionFalse, reasonMinimumReplicasUnavail,
+			"Deployment does not have minimum availability")
+	}
+
+	deploymentType := resourceConditionType("Deployment")
+	workload.Status.ObservedGeneration = workload.Generation
+
+	if cond := deploymentCondition(deployment, appsv1.DeploymentProgressing); cond != nil &&
+		cond.Reason == reasonProgressDeadlineExceeded {
+		message := fmt.Sprintf("Deployment (%s) exceeded its progress deadline: %s", deployment.Name, cond.Message)
+		setCondition(workload, deploymentType, metav1.ConditionFalse, reasonProgressDeadlineExceeded, message)
+		setFailed(workload, reasonProgressDeadlineExceeded, message)
+		return
+	}
+
+	if !deploymentRolledOut(deployment) {
+		setRollingOut(workload, deploymentType, fmt.Sprintf("Deployment (%s) is rolling out: %d of %d updated replicas are available",
+			deployment.Name, deployment.Status.AvailableReplicas, desiredReplicas(deployment)))
+		return
+	}
+
+	setCondition(workload, deploymentType, metav1.ConditionTrue, reasonSucceeded,
+		fmt.Sprintf("Deployment (%s) is rolled out", deployment.Name))
+	setSucceeded(workload)
+}
+
+// setStatefulSetConditions sets the StatefulSetReady and Available conditions
+// from the status of the stateful set, and the aggregate conditions of the
+// workload from its rollout.
+func setStatefulSetConditions(workload *platformv1.Workload, statefulSet *appsv1.StatefulSet) {
+	status := statefulSet.Status
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+
+	if status.AvailableReplicas >= replicas {
+		setCondition(workload, typeAvailableWorkload, metav1.ConditionTrue, reasonMinimumReplicasAvailable,
+			"StatefulSet has minimum availability")
+	} else {
+		setCondition(workload, typeAvailableWorkload, metav1.ConditionFalse, reasonMinimumReplicasUnavail,
+			"StatefulSet does not have minimum availability")
+	}
+
+	statefulSetType := resourceConditionType("StatefulSet")
+	workload.Status.ObservedGeneration = workload.Generation
+
+	rolledOut := status.ObservedGeneration >= statefulSet.Generation &&
+		status.UpdateRevision == status.CurrentRevision &&
+		status.UpdatedReplicas == replicas &&
+		status.AvailableReplicas == replicas
+	if !rolledOut {
+		setRollingOut(workload, statefulSetType, fmt.Sprintf("StatefulSet (%s) is rolling out: %d of %d updated replicas are available",
+			statefulSet.Name, status.AvailableReplicas, replicas))
+		return
+	}
+
+	setCondition(workload, statefulSetType, metav1.ConditionTrue, reasonSucceeded,
+		fmt.Sprintf("StatefulSet (%s) is rolled out", statefulSet.Name))
+	setSucceeded(workload)
+}
+
+// setCanaryConditions marks the workload as progressing while a canary is
+// running and as degraded when its last canary was aborted.
+func setCanaryConditions(workload *platformv1.Workload) {
+	canary := workload.Status.Canary
+	if canary == nil || !usesCanary(*workload) {
+		return
+	}
+
+	switch canary.Phase {
+	case platformv1.CanaryPhaseProgressing:
+		setRollingOut(workload, resourceConditionType("Deployment"), canary.Message)
+		setCondition(workload, typeProgressingWorkload, metav1.ConditionTrue, reasonCanaryProgressing, canary.Message)
+	case platformv1.CanaryPhaseAborted:
+		setCondition(workload, resourceConditionType("Deployment"), metav1.ConditionFalse, reasonCanaryAborted, canary.Message)
+		setFailed(workload, reasonCanaryAborted, canary.Message)
+	}
+}
+
+// setBlueGreenConditions marks the workload as progressing while a preview
+// awaits its promotion.
+func setBlueGreenConditions(workload *platformv1.Workload) {
+	blueGreen := workload.Status.BlueGreen
+	if blueGreen == nil || blueGreen.PreviewColor == "" {
+		return
+	}
+
+	setRollingOut(workload, resourceConditionType("Deployment"), blueGreen.Message)
+	setCondition(workload, typeProgressingWorkload, metav1.ConditionTrue, reasonAwaitingPromotion, blueGreen.Message)
+}
+
+// setDriftCondition sets the Drifted condition from the drift of the applied
+// objects of the workload, which was corrected with correctDrift.
+func setDriftCondition(workload *platformv1.Workload, drift []string, correctDrift bool) {
+	if len(drift) == 0 {
+		setCondition(workload, typeDriftedWorkload, metav1.ConditionFalse, reasonNoDrift, "No fields were changed by others")
+		return
+	}
+
+	reason, message := reasonDriftCorrected, "Fields were changed by others, fields added by others are left in place"
+	if !correctDrift {
+		reason, message = reasonDriftDetected, "Fields were changed by others"
+	}
+	setCondition(workload, typeDriftedWorkload, metav1.ConditionTrue, reason,
+		fmt.Sprintf("%s: %s", message, strings.Join(drift, "; ")))
+}
+
+// setRollingOut marks the workload and the object of the given condition type
+// as progressing.
+func setRollingOut(workload *platformv1.Workload, conditionType, message string) {
+	setCondition(workload, conditionType, metav1.ConditionFalse, reasonRollingOut, message)
+	setCondition(workload, typeReadyWorkload, metav1.ConditionFalse, reasonRollingOut, message)
+	setCondition(workload, typeProgressingWorkload, metav1.ConditionTrue, reasonRollingOut, message)
+	setCondition(workload, typeDegradedWorkload, metav1.ConditionFalse, reasonRollingOut, message)
+}
+
+// setSucceeded marks the workload as ready.
+func setSucceeded(workload *platformv1.Workload) {
+	message := fmt.Sprintf("Resources for custom resource (%s) created successfully", workload.Name)
+	setCondition(workload, typeReadyWorkload, metav1.ConditionTrue, reasonSucceeded, message)
+	setCondition(workload, typeProgressingWorkload, metav1.ConditionFalse, reasonSucceeded, message)
+	setCondition(workload, typeDegradedWorkload, metav1.ConditionFalse, reasonSucceeded, message)
+}
+
+// setJobConditions sets the JobReady condition and the aggregate conditions of
+// the workload from the run of the job.
+func setJobConditions(workload *platformv1.Workload, job *batchv1.Job) {
+	meta.RemoveStatusCondition(&workload.Status.Conditions, typeAvailableWorkload)
+	workload.Status.ObservedGeneration = workload.Generation
+	jobType := resourceConditionType("Job")
+
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		switch cond.Type {
+		case batchv1.JobFailed:
+			message := fmt.Sprintf("Job (%s) failed: %s", job.Name, cond.Message)
+			setCondition(workload, jobType, metav1.ConditionFalse, reasonJobFailed, message)
+			setFailed(workload, reasonJobFailed, message)
+			return
+		case batchv1.JobComplete:
+			setCondition(workload, jobType, metav1.ConditionTrue, reasonSucceeded,
+				fmt.Sprintf("Job (%s) completed", job.Name))
+			setSucceeded(workload)
+			return
+		}
+	}
+
+	message := fmt.Sprintf("Job (%s) is running", job.Name)
+	setCondition(workload, jobType, metav1.ConditionFalse, reasonRunning, message)
+	setCondition(workload, typeReadyWorkload, metav1.ConditionFalse, reasonRunning, message)
+	setCondition(workload, typeProgressingWorkload, metav1.ConditionTrue, reasonRunning, message)
+	setCondition(workload, typeDegradedWorkload, metav1.ConditionFalse, reasonRunning, message)
+}
+
+// setCronJobConditions sets the CronJobReady condition and the aggregate
+// conditions of the workload. A CronJob is ready once it is scheduled; the
+// outcome of its runs is reported by the last run and success times.
+func setCronJobConditions(workload *platformv1.Workload, cronJob *batchv1.CronJob) {
+	meta.RemoveStatusCondition(&workload.Status.Conditions, typeAvailableWorkload)
+	workload.Status.ObservedGeneration = workload.Generation
+
+	setCondition(workload, resourceConditionType("CronJob"), metav1.ConditionTrue, reasonScheduled,
+		fmt.Sprintf("CronJob (%s) is scheduled at %s", cronJob.Name, cronJob.Spec.Schedule))
+	setSucceeded(workload)
+}
+
+// deploymentCondition returns the condition of the deployment with the given type.
+func deploymentCondition(deployment *appsv1.Deployment, conditionType appsv1.DeploymentConditionType) *appsv1.DeploymentCondition {
+	for i := range deployment.Status.Conditions {
+		if deployment.Status.Conditions[i].Type == conditionType {
+			return &deployment.Status.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// desiredReplicas returns the number of replicas the deployment asks for.
+func desiredReplicas(deployment *appsv1.Deployment) int32 {
+	if deployment.Spec.Replicas == nil {
+		return 1
+	}
+
+	return *deployment.Spec.Replicas
+}
+
+// deploymentRolledOut reports whether the deployment controller has observed
+// the latest spec and all replicas are updated and available.
+func deploymentRolledOut(deployment *appsv1.Deployment) bool {
+	status := deployment.Status
+	replicas := desiredReplicas(deployment)
+
+	return status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == replicas &&
+		status.Replicas == replicas &&
+		status.AvailableReplicas == replicas
+}
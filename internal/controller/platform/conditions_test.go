@@ -0,0 +1,217 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// expectConditions fails the test unless the conditions of the workload have
+// the statuses.
+func expectConditions(t *testing.T, conditions []metav1.Condition, want map[string]metav1.ConditionStatus) {
+	t.Helper()
+
+	for conditionType, status := range want {
+		condition := meta.FindStatusCondition(conditions, conditionType)
+		if condition == nil || condition.Status != status {
+			t.Errorf("expected %s to be %s, got %v", conditionType, status, condition)
+		}
+	}
+}
+
+func TestSetDeploymentConditions(t *testing.T) {
+	available := appsv1.DeploymentCondition{Type: appsv1.DeploymentAvailable, Status: corev1.ConditionTrue}
+	deadlineExceeded := appsv1.DeploymentCondition{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse,
+		Reason: reasonProgressDeadlineExceeded, Message: "ReplicaSet web-1 has timed out progressing."}
+
+	tests := []struct {
+		name       string
+		deployment func() *appsv1.Deployment
+		want       map[string]metav1.ConditionStatus
+	}{
+		{
+			name: "rolled out",
+			deployment: func() *appsv1.Deployment {
+				deployment := rolledOut(testDeployment("web", "app:v1", 4))
+				deployment.Status.Conditions = []appsv1.DeploymentCondition{available}
+				return deployment
+			},
+			want: map[string]metav1.ConditionStatus{
+				typeReadyWorkload:                   metav1.ConditionTrue,
+				typeProgressingWorkload:             metav1.ConditionFalse,
+				typeDegradedWorkload:                metav1.ConditionFalse,
+				typeAvailableWorkload:               metav1.ConditionTrue,
+				resourceConditionType("Deployment"): metav1.ConditionTrue,
+			},
+		},
+		{
+			name: "rolling out",
+			deployment: func() *appsv1.Deployment {
+				deployment := rolledOut(testDeployment("web", "app:v1", 4))
+				deployment.Generation = 2
+				deployment.Status.Conditions = []appsv1.DeploymentCondition{available}
+				return deployment
+			},
+			want: map[string]metav1.ConditionStatus{
+				typeReadyWorkload:                   metav1.ConditionFalse,
+				typeProgressingWorkload:             metav1.ConditionTrue,
+				typeDegradedWorkload:                metav1.ConditionFalse,
+				typeAvailableWorkload:               metav1.ConditionTrue,
+				resourceConditionType("Deployment"): metav1.ConditionFalse,
+			},
+		},
+		{
+			name: "progress deadline exceeded",
+			deployment: func() *appsv1.Deployment {
+				deployment := testDeployment("web", "app:v1", 4)
+				deployment.Status.Conditions = []appsv1.DeploymentCondition{deadlineExceeded}
+				return deployment
+			},
+			want: map[string]metav1.ConditionStatus{
+				typeReadyWorkload:                   metav1.ConditionFalse,
+				typeProgressingWorkload:             metav1.ConditionFalse,
+				typeDegradedWorkload:                metav1.ConditionTrue,
+				typeAvailableWorkload:               metav1.ConditionFalse,
+				resourceConditionType("Deployment"): metav1.ConditionFalse,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := testWorkload("app:v1")
+			workload.Generation = 3
+
+			setDeploymentConditions(workload, tt.deployment())
+			expectConditions(t, workload.Status.Conditions, tt.want)
+			if workload.Status.ObservedGeneration != 3 {
+				t.Errorf("expected the observed generation 3, got %d", workload.Status.ObservedGeneration)
+			}
+			for _, condition := range workload.Status.Conditions {
+				if condition.ObservedGeneration != 3 {
+					t.Errorf("expected %s to be observed at generation 3, got %d", condition.Type, condition.ObservedGeneration)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneResourceConditions(t *testing.T) {
+	workload := testWorkload("app:v1")
+	setCondition(workload, resourceConditionType("Deployment"), metav1.ConditionTrue, reasonApplied, "")
+	setCondition(workload, resourceConditionType("Ingress"), metav1.ConditionTrue, reasonApplied, "")
+	setSucceeded(workload)
+
+	pruneResourceConditions(workload, map[string]bool{"Deployment": true})
+	if meta.FindStatusCondition(workload.Status.Conditions, resourceConditionType("Ingress")) != nil {
+		t.Error("expected the condition of the Ingress to be removed")
+	}
+	expectConditions(t, workload.Status.Conditions, map[string]metav1.ConditionStatus{
+		resourceConditionType("Deployment"): metav1.ConditionTrue,
+		typeReadyWorkload:                   metav1.ConditionTrue,
+	})
+}
+
+func TestWorkloadPhase(t *testing.T) {
+	tests := []struct {
+		name      string
+		change    func(*platformv1.Workload)
+		wantPhase platformv1.WorkloadPhase
+	}{
+		{name: "no conditions", wantPhase: platformv1.WorkloadPhasePending},
+		{
+			name:      "rolling out",
+			change:    func(w *platformv1.Workload) { setRollingOut(w, resourceConditionType("Deployment"), "") },
+			wantPhase: platformv1.WorkloadPhaseProgressing,
+		},
+		{name: "ready", change: setSucceeded, wantPhase: platformv1.WorkloadPhaseReady},
+		{
+			name:      "degraded",
+			change:    func(w *platformv1.Workload) { setFailed(w, reasonApplyFailed, "") },
+			wantPhase: platformv1.WorkloadPhaseDegraded,
+		},
+		{
+			name: "terminating",
+			change: func(w *platformv1.Workload) {
+				setSucceeded(w)
+				now := metav1.Now()
+				w.DeletionTimestamp = &now
+			},
+			wantPhase: platformv1.WorkloadPhaseTerminating,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := testWorkload("app:v1")
+			if tt.change != nil {
+				tt.change(workload)
+			}
+			if got := workloadPhase(workload); got != tt.wantPhase {
+				t.Errorf("workloadPhase() = %s, want %s", got, tt.wantPhase)
+			}
+		})
+	}
+}
+
+func TestReconcileReplicaSummary(t *testing.T) {
+	ctx := context.Background()
+	workload := testWorkload("app:v1")
+	r := newTestReconciler(workload)
+	key := client.ObjectKeyFromObject(workload)
+
+	reconcile := func() {
+		t.Helper()
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Get(ctx, key, workload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reconcile()
+	if workload.Status.Summary != "0/4" || workload.Status.Phase != platformv1.WorkloadPhaseProgressing {
+		t.Errorf("expected 0/4 replicas progressing, got %s %s", workload.Status.Summary, workload.Status.Phase)
+	}
+
+	var deployment appsv1.Deployment
+	if err := r.Get(ctx, key, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Update(ctx, rolledOut(&deployment)); err != nil {
+		t.Fatal(err)
+	}
+	reconcile()
+	if workload.Status.Summary != "4/4" || workload.Status.Phase != platformv1.WorkloadPhaseReady {
+		t.Errorf("expected 4/4 replicas ready, got %s %s", workload.Status.Summary, workload.Status.Phase)
+	}
+	if workload.Status.DesiredReplicas != 4 || workload.Status.ReadyReplicas != 4 || workload.Status.AvailableReplicas != 4 {
+		t.Errorf("expected the replicas of the Deployment, got %d desired, %d ready and %d available",
+			workload.Status.DesiredReplicas, workload.Status.ReadyReplicas, workload.Status.AvailableReplicas)
+	}
+}
@@ -0,0 +1,284 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+const (
+	// configHashAnnotation is set on the pod template to roll out the pods when
+	// the configuration or the referenced secrets change
+	configHashAnnotation = "platform.mydev.org/config-hash"
+
+	// configVolumeName is the name of the volume with the configuration files
+	configVolumeName = "config"
+
+	// defaultConfigMountPath is the directory of the configuration files
+	defaultConfigMountPath = "/etc/config"
+
+	// secretRefsIndex indexes workloads by the names of their referenced secrets
+	secretRefsIndex = ".spec.secretRefs.name"
+
+	// missingSecretsPollInterval is how often a workload referencing secrets
+	// that do not exist is reconciled again
+	missingSecretsPollInterval = time.Minute
+)
+
+// configMapName returns the name of the ConfigMap of a workload.
+func configMapName(workload platformv1.Workload) string {
+	return fmt.Sprintf("%s-config", workload.Name)
+}
+
+// sortedKeys returns the keys of the map in a stable order.
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// desiredConfigMap returns the ConfigMap with the configuration of the workload,
+// or nil when the workload has no configuration.
+func (r *WorkloadReconciler) desiredConfigMap(workload platformv1.Workload) (*corev1.ConfigMap, error) {
+	config := workload.Spec.Config
+	if config == nil {
+		return nil, nil
+	}
+
+	data := map[string]string{}
+	for key, value := range config.Env {
+		data[key] = value
+	}
+	for _, file := range config.Files {
+		data[file.Name] = file.Content
+	}
+
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      configMapName(workload),
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Data: data,
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, configMap, r.Scheme); err != nil {
+		return configMap, err
+	}
+
+	return configMap, nil
+}
+
+// configHash returns a hash of the configuration and the data of the secrets
+// referenced by the workload, or an empty string when there are none. It also
+// returns the names of the referenced secrets that do not exist and are not
+// optional.
+func (r *WorkloadReconciler) configHash(ctx context.Context, workload platformv1.Workload, configMap *corev1.ConfigMap) (string, []string, error) {
+	if configMap == nil && len(workload.Spec.SecretRefs) == 0 {
+		return "", nil, nil
+	}
+
+	hash := sha256.New()
+	if configMap != nil {
+		for _, key := range sortedKeys(configMap.Data) {
+			fmt.Fprintf(hash, "configmap/%s=%s\n", key, configMap.Data[key])
+		}
+	}
+
+	var missing []string
+	for _, ref := range workload.Spec.SecretRefs {
+		var secret corev1.Secret
+		key := types.NamespacedName{Namespace: workload.Namespace, Name: ref.Name}
+		if err := r.Get(ctx, key, &secret); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return "", nil, fmt.Errorf("failed to get secret %s: %w", key, err)
+			}
+			if !ref.Optional {
+				missing = append(missing, ref.Name)
+			}
+			fmt.Fprintf(hash, "secret/%s absent\n", ref.Name)
+			continue
+		}
+
+		for _, dataKey := range sortedKeys(secret.Data) {
+			fmt.Fprintf(hash, "secret/%s/%s=%x\n", ref.Name, dataKey, secret.Data[dataKey])
+		}
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), missing, nil
+}
+
+// injectConfig adds the configuration and the referenced secrets of the
+// workload to the pod template.
+func injectConfig(workload platformv1.Workload, template *corev1.PodTemplateSpec, configHash string) {
+	if configHash != "" {
+		if template.Annotations == nil {
+			template.Annotations = map[string]string{}
+		}
+		template.Annotations[configHashAnnotation] = configHash
+	}
+
+	// copy the env of the container, it shares its backing array with the workload spec
+	container := &template.Spec.Containers[0]
+	container.Env = append([]corev1.EnvVar{}, container.Env...)
+
+	if config := workload.Spec.Config; config != nil {
+		for _, key := range sortedKeys(config.Env) {
+			container.Env = append(container.Env, corev1.EnvVar{
+				Name: key,
+				ValueFrom: &corev1.EnvVarSource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: configMapName(workload)},
+						Key:                  key,
+					},
+				},
+			})
+		}
+
+		if len(config.Files) > 0 {
+			items := make([]corev1.KeyToPath, 0, len(config.Files))
+			for _, file := range config.Files {
+				items = append(items, corev1.KeyToPath{Key: file.Name, Path: file.Name})
+			}
+
+			template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+				Name: configVolumeName,
+				VolumeSource: corev1.VolumeSource{
+					ConfigMap: &corev1.ConfigMapVolumeSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: configMapName(workload)},
+						Items:                items,
+					},
+				},
+			})
+
+			mountPath := config.MountPath
+			if mountPath == "" {
+				mountPath = defaultConfigMountPath
+			}
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      configVolumeName,
+				MountPath: mountPath,
+				ReadOnly:  true,
+			})
+		}
+	}
+
+	for i, ref := range workload.Spec.SecretRefs {
+		optional := ref.Optional
+
+		if ref.MountPath == "" {
+			container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+					Optional:             &optional,
+				},
+			})
+			continue
+		}
+
+		volumeName := fmt.Sprintf("secret-%d", i)
+		template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+			Name: volumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: ref.Name,
+					Optional:   &optional,
+				},
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      volumeName,
+			MountPath: ref.MountPath,
+			ReadOnly:  true,
+		})
+	}
+}
+
+// secretRefNames returns the names of the secrets referenced by a workload, to
+// index workloads by them.
+func secretRefNames(obj client.Object) []string {
+	workload, ok := obj.(*platformv1.Workload)
+	if !ok {
+		return nil
+	}
+
+	names := make([]string, 0, len(workload.Spec.SecretRefs))
+	for _, ref := range workload.Spec.SecretRefs {
+		names = append(names, ref.Name)
+	}
+
+	return names
+}
+
+// workloadsForSecret maps a change of a Secret to the workloads copying it as
+// an image pull secret and the workloads referencing it.
+func (r *WorkloadReconciler) workloadsForSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	requests := r.workloadsForPullSecret(ctx, obj)
+
+	seen := map[types.NamespacedName]bool{}
+	for _, request := range requests {
+		seen[request.NamespacedName] = true
+	}
+	for _, request := range r.workloadsForSecretRef(ctx, obj) {
+		if !seen[request.NamespacedName] {
+			requests = append(requests, request)
+		}
+	}
+
+	return requests
+}
+
+// workloadsForSecretRef maps a change of a secret to the workloads referencing
+// it, so that their pods are rolled out.
+func (r *WorkloadReconciler) workloadsForSecretRef(ctx context.Context, obj client.Object) []reconcile.Request {
+	var workloads platformv1.WorkloadList
+	if err := r.List(ctx, &workloads, client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{secretRefsIndex: obj.GetName()}); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list workloads for secret", "secret", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(workloads.Items))
+	for _, workload := range workloads.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: workload.Namespace, Name: workload.Name},
+		})
+	}
+
+	return requests
+}
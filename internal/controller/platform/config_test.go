@@ -0,0 +1,103 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+func TestConfigHash(t *testing.T) {
+	ctx := context.Background()
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Data:       map[string][]byte{"password": []byte("v1")},
+	}
+	r := newTestReconciler(secret)
+
+	workload := testWorkload("app:v1")
+	workload.Spec.SecretRefs = []platformv1.SecretReference{{Name: "db"}, {Name: "extra", Optional: true}}
+	configMap, err := r.desiredConfigMap(*workload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, missing, err := r.configHash(ctx, *workload, configMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash == "" || len(missing) != 0 {
+		t.Fatalf("expected a hash without missing secrets, got %q and %v", hash, missing)
+	}
+
+	// a changed secret rolls out the pods
+	secret.Data["password"] = []byte("v2")
+	if err := r.Update(ctx, secret); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _, _ := r.configHash(ctx, *workload, configMap); changed == hash {
+		t.Error("expected the hash to change with the secret")
+	}
+
+	// only required secrets are missing
+	workload.Spec.SecretRefs = append(workload.Spec.SecretRefs, platformv1.SecretReference{Name: "api-key"})
+	if _, missing, err := r.configHash(ctx, *workload, configMap); err != nil || len(missing) != 1 || missing[0] != "api-key" {
+		t.Errorf("expected api-key to be missing, got %v (%v)", missing, err)
+	}
+}
+
+func TestReconcileMissingSecret(t *testing.T) {
+	ctx := context.Background()
+	workload := testWorkload("app:v1")
+	workload.Spec.SecretRefs = []platformv1.SecretReference{{Name: "db"}}
+	r := newTestReconciler(workload)
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(workload)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.RequeueAfter != missingSecretsPollInterval {
+		t.Errorf("expected a requeue after %s, got %s", missingSecretsPollInterval, result.RequeueAfter)
+	}
+	expectEvent(t, r, reasonSecretMissing)
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(workload), workload); err != nil {
+		t.Fatal(err)
+	}
+	degraded := meta.FindStatusCondition(workload.Status.Conditions, typeDegradedWorkload)
+	if degraded == nil || degraded.Status != metav1.ConditionTrue || degraded.Reason != reasonSecretMissing ||
+		!strings.Contains(degraded.Message, "db") {
+		t.Errorf("expected the workload to be degraded by the missing secret db, got %v", degraded)
+	}
+
+	// the other objects of the workload are applied
+	if err := r.Get(ctx, client.ObjectKeyFromObject(workload), &corev1.ServiceAccount{}); err != nil {
+		t.Errorf("expected the ServiceAccount to be applied, got %v", err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(workload), &appsv1.Deployment{}); err != nil {
+		t.Errorf("expected the Deployment to be applied, got %v", err)
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/structured-merge-diff/v4/fieldpath"
+	"sigs.k8s.io/structured-merge-diff/v4/value"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+const (
+	// appliedHashAnnotation is the hash of an object as applied by the
+	// operator. While it matches, fields of the desired object that are
+	// missing from the live object were removed by others.
+	appliedHashAnnotation = "platform.mydev.org/applied-hash"
+)
+
+// operatorFieldOwners are the field managers of the operator itself. Fields
+// taken over from them are not drift.
+var operatorFieldOwners = []string{fieldOwner, replicasFieldOwner}
+
+// systemFieldOwners are the field managers of Kubernetes controllers, which
+// record their own state on the objects, e.g. the revision of Deployments.
+var systemFieldOwners = []string{"kube-controller-manager"}
+
+// driftedField is a field of an object changed by others.
+type driftedField struct {
+	path fieldpath.Path
+	// manager is the field manager of the field, it is empty when the field
+	// was removed.
+	manager string
+	// added is set when the desired object does not have the field
+	added bool
+}
+
+func (f driftedField) String() string {
+	switch {
+	case f.manager == "":
+		return fmt.Sprintf("%s (removed)", f.path)
+	case f.added:
+		return fmt.Sprintf("%s (added by %s)", f.path, f.manager)
+	}
+	return fmt.Sprintf("%s (%s)", f.path, f.manager)
+}
+
+// objectDrift is the drift of a live object from its desired state.
+type objectDrift struct {
+	// modified are the fields of the desired object set to other values by others
+	modified []driftedField
+	// added are the fields set by others that the desired object does not
+	// have. They are not applied by the operator and left in place.
+	added []driftedField
+	// removed are the fields of the desired object removed by others
+	removed []driftedField
+}
+
+// fields returns the drifted fields, formatted as "<field> (<manager>)".
+func (d objectDrift) fields() []string {
+	return fieldNames(d.modified, d.added, d.removed)
+}
+
+// fieldNames returns the formatted fields.
+func fieldNames(fields ...[]driftedField) []string {
+	var names []string
+	for _, drifted := range fields {
+		for _, field := range drifted {
+			names = append(names, field.String())
+		}
+	}
+
+	return names
+}
+
+// setAppliedHash sets the applied hash annotation of the object to the hash of
+// the object.
+func setAppliedHash(obj client.Object) error {
+	hash, err := specHash(obj)
+	if err != nil {
+		return err
+	}
+
+	annotations := map[string]string{appliedHashAnnotation: hash}
+	for key, value := range obj.GetAnnotations() {
+		annotations[key] = value
+	}
+	obj.SetAnnotations(annotations)
+
+	return nil
+}
+
+// driftedFields compares the live object with the desired object. The fields
+// managed by others, as recorded in the managed fields of the live object, are
+// drift when the desired object does not have them or has another value. The
+// fields of the desired object missing from the live object are drift when the
+// desired object did not change since it was applied.
+func driftedFields(live, desired client.Object) (objectDrift, error) {
+	var drift objectDrift
+
+	liveContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(live)
+	if err != nil {
+		return drift, err
+	}
+	desiredContent, err := runtime.DefaultUnstructuredConverter.ToUnstructured(desired)
+	if err != nil {
+		return drift, err
+	}
+
+	for _, entry := range live.GetManagedFields() {
+		// the status and the scale are not applied by the operator
+		if entry.Subresource != "" || entry.FieldsV1 == nil ||
+			containsString(operatorFieldOwners, entry.Manager) || containsString(systemFieldOwners, entry.Manager) {
+			continue
+		}
+
+		set := &fieldpath.Set{}
+		if err := set.FromJSON(bytes.NewReader(entry.FieldsV1.Raw)); err != nil {
+			return drift, fmt.Errorf("parsing managed fields of %s: %w", entry.Manager, err)
+		}
+		set.Leaves().Iterate(func(path fieldpath.Path) {
+			if !driftRelevant(path) {
+				return
+			}
+
+			liveValue, _ := fieldValue(liveContent, path)
+			desiredValue, found := fieldValue(desiredContent, path)
+			switch {
+			case !found:
+				added := driftedField{path: addedPath(desiredContent, path).Copy(), manager: entry.Manager, added: true}
+				if n := len(drift.added); n == 0 || !drift.added[n-1].path.Equals(added.path) {
+					drift.added = append(drift.added, added)
+				}
+			case !value.Equals(value.NewValueInterface(liveValue), value.NewValueInterface(desiredValue)):
+				drift.modified = append(drift.modified, driftedField{path: path.Copy(), manager: entry.Manager})
+			}
+		})
+	}
+
+	// a changed desired object may add fields, they are not removed by others
+	hash := desired.GetAnnotations()[appliedHashAnnotation]
+	if hash == "" || live.GetAnnotations()[appliedHashAnnotation] != hash {
+		return drift, nil
+	}
+	fieldpath.SetFromValue(value.NewValueInterface(desiredContent)).Iterate(func(path fieldpath.Path) {
+		if !driftRelevant(path) {
+			return
+		}
+		if desiredValue, _ := fieldValue(desiredContent, path); desiredValue == nil {
+			return
+		}
+		if _, found := fieldValue(liveContent, path); !found {
+			drift.removed = append(drift.removed, driftedField{path: path.Copy()})
+		}
+	})
+
+	return drift, nil
+}
+
+// addedPath returns the path of a field that the desired object does not have:
+// the list item when the desired object does not have the item, so that it is
+// removed as a whole, or the field itself.
+func addedPath(desiredContent map[string]interface{}, path fieldpath.Path) fieldpath.Path {
+	for i, element := range path {
+		if element.FieldName != nil {
+			continue
+		}
+		if _, found := fieldValue(desiredContent, path[:i+1]); !found {
+			return path[:i+1]
+		}
+	}
+
+	return path
+}
+
+// driftRelevant reports whether a field is compared for drift. Type meta and
+// the metadata, apart from labels and annotations, are set by the API server
+// and by other controllers.
+func driftRelevant(path fieldpath.Path) bool {
+	if len(path) == 0 || path[0].FieldName == nil {
+		return false
+	}
+
+	switch *path[0].FieldName {
+	case "apiVersion", "kind", "status":
+		return false
+	case "metadata":
+		return len(path) > 1 && path[1].FieldName != nil &&
+			(*path[1].FieldName == "labels" || *path[1].FieldName == "annotations")
+	}
+
+	return true
+}
+
+// fieldValue returns the value of the field at the path of the object content.
+func fieldValue(content interface{}, path fieldpath.Path) (interface{}, bool) {
+	current := content
+	for _, element := range path {
+		var found bool
+		switch {
+		case element.FieldName != nil:
+			fields, ok := current.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			current, found = fields[*element.FieldName]
+		default:
+			items, ok := current.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			var index int
+			if index, found = listIndex(items, element); found {
+				current = items[index]
+			}
+		}
+		if !found {
+			return nil, false
+		}
+	}
+
+	return current, true
+}
+
+// listIndex returns the index of the list item selected by the path element.
+func listIndex(items []interface{}, element fieldpath.PathElement) (int, bool) {
+	switch {
+	case element.Index != nil:
+		return *element.Index, *element.Index >= 0 && *element.Index < len(items)
+	case element.Value != nil:
+		for i, item := range items {
+			if value.Equals(value.NewValueInterface(item), *element.Value) {
+				return i, true
+			}
+		}
+	case element.Key != nil:
+		// key fields missing from a desired item are defaulted by the API
+		// server, e.g. the protocol of ports
+	items:
+		for i, item := range items {
+			fields, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			matched := false
+			for _, key := range *element.Key {
+				field, ok := fields[key.Name]
+				if !ok {
+					continue
+				}
+				if !value.Equals(value.NewValueInterface(field), key.Value) {
+					continue items
+				}
+				matched = true
+			}
+			if matched {
+				return i, true
+			}
+		}
+	}
+
+	return 0, false
+}
+
+// deleteFields deletes the fields from the object content.
+func deleteFields(content map[string]interface{}, fields ...[]driftedField) {
+	for _, drifted := range fields {
+		for _, field := range drifted {
+			deleteField(content, field.path)
+		}
+	}
+}
+
+// deleteField deletes the field at the path from the object content.
+func deleteField(content interface{}, path fieldpath.Path) interface{} {
+	if len(path) == 0 {
+		return content
+	}
+
+	element := path[0]
+	switch current := content.(type) {
+	case map[string]interface{}:
+		if element.FieldName == nil {
+			return current
+		}
+		child, found := current[*element.FieldName]
+		switch {
+		case !found:
+		case len(path) == 1:
+			delete(current, *element.FieldName)
+		default:
+			current[*element.FieldName] = deleteField(child, path[1:])
+		}
+		return current
+	case []interface{}:
+		index, found := listIndex(current, element)
+		switch {
+		case !found:
+		case len(path) == 1:
+			return append(current[:index:index], current[index+1:]...)
+		default:
+			current[index] = deleteField(current[index], path[1:])
+		}
+		return current
+	}
+
+	return content
+}
+
+// reportsDrift reports whether drift of the objects of the workload is left in place.
+func reportsDrift(workload platformv1.Workload) bool {
+	return workload.Spec.DriftPolicy == platformv1.DriftPolicyReport
+}
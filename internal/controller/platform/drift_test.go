@@ -0,0 +1,193 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+func TestDriftedFields(t *testing.T) {
+	desired := driftDeployment()
+	if err := setAppliedHash(desired); err != nil {
+		t.Fatal(err)
+	}
+	container := `.spec.template.spec.containers[name="` + workloadContainerName + `"]`
+
+	tests := []struct {
+		name string
+		live func() *appsv1.Deployment
+		want []string
+	}{
+		{
+			name: "no drift",
+			live: func() *appsv1.Deployment {
+				live := desired.DeepCopy()
+				live.ManagedFields = []metav1.ManagedFieldsEntry{
+					managedFieldsEntry(fieldOwner, "", `{"f:spec":{"f:template":{"f:spec":{"f:containers":{}}}}}`),
+				}
+				return live
+			},
+		},
+		{
+			name: "changed and added fields",
+			live: func() *appsv1.Deployment {
+				return editedDeployment(desired)
+			},
+			want: []string{
+				container + ".image (kubectl-edit)",
+				container + `.env[name="DEBUG"] (added by kubectl-edit)`,
+			},
+		},
+		{
+			name: "removed field",
+			live: func() *appsv1.Deployment {
+				live := desired.DeepCopy()
+				delete(live.Labels, "team")
+				return live
+			},
+			want: []string{".metadata.labels.team (removed)"},
+		},
+		{
+			name: "field of a changed desired object",
+			live: func() *appsv1.Deployment {
+				live := desired.DeepCopy()
+				delete(live.Labels, "team")
+				live.Annotations[appliedHashAnnotation] = "previous"
+				return live
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			drift, err := driftedFields(tt.live(), desired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := drift.fields(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("driftedFields() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyChildCorrectsDrift(t *testing.T) {
+	ctx := context.Background()
+	desired := driftDeployment(corev1.EnvVar{Name: "MODE", Value: "prod"})
+	r := newTestReconciler(editedDeployment(desired))
+	workload := testWorkload("app:v1")
+
+	drift, err := r.applyChild(ctx, workload, desired.DeepCopy(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(drift, "image (kubectl-edit)") {
+		t.Errorf("expected the changed image to be drift, got %q", drift)
+	}
+	expectEvent(t, r, reasonDriftCorrected)
+	// the env var added by others is reported but not removed
+	expectEvent(t, r, reasonDriftDetected)
+
+	var live appsv1.Deployment
+	if err := r.Get(ctx, client.ObjectKeyFromObject(desired), &live); err != nil {
+		t.Fatal(err)
+	}
+	container := live.Spec.Template.Spec.Containers[0]
+	if container.Image != "app:v1" {
+		t.Errorf("expected the image to be corrected, got %s", container.Image)
+	}
+	want := append(desired.Spec.Template.Spec.Containers[0].Env, corev1.EnvVar{Name: "DEBUG", Value: "true"})
+	if !reflect.DeepEqual(container.Env, want) {
+		t.Errorf("expected the added env var to be kept, got %v", container.Env)
+	}
+}
+
+func TestApplyChildKeepsAddedFields(t *testing.T) {
+	ctx := context.Background()
+	desired := driftDeployment()
+	live := desired.DeepCopy()
+	live.ResourceVersion = "1"
+	live.Spec.Template.Annotations = map[string]string{"kubectl.kubernetes.io/restartedAt": "2023-06-01T00:00:00Z"}
+	live.ManagedFields = []metav1.ManagedFieldsEntry{
+		managedFieldsEntry("kubectl-rollout", "", `{"f:spec":{"f:template":{"f:metadata":{"f:annotations":{`+
+			`"f:kubectl.kubernetes.io/restartedAt":{}}}}}}`),
+	}
+	r := newTestReconciler(live)
+
+	drift, err := r.applyChild(ctx, testWorkload("app:v1"), desired.DeepCopy(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(drift, "restartedAt (added by kubectl-rollout)") {
+		t.Errorf("expected the added annotation to be reported, got %q", drift)
+	}
+	expectEvent(t, r, reasonDriftDetected)
+
+	var got appsv1.Deployment
+	if err := r.Get(ctx, client.ObjectKeyFromObject(desired), &got); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := got.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"]; !found {
+		t.Error("expected the restart annotation to be kept")
+	}
+}
+
+func TestApplyChildReportsDrift(t *testing.T) {
+	ctx := context.Background()
+	desired := driftDeployment(corev1.EnvVar{Name: "MODE", Value: "prod"})
+	r := newTestReconciler(editedDeployment(desired))
+	workload := testWorkload("app:v1")
+	workload.Spec.DriftPolicy = platformv1.DriftPolicyReport
+
+	// the workload is scaled while the Deployment drifted
+	scaled := desired.DeepCopy()
+	scaled.Spec.Replicas = pointer.Int32(3)
+	drift, err := r.applyChild(ctx, workload, scaled, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(drift, "image (kubectl-edit)") || !strings.Contains(drift, `env[name="DEBUG"]`) {
+		t.Errorf("expected the changed image and the added env var to be drift, got %q", drift)
+	}
+	expectEvent(t, r, reasonDriftDetected)
+
+	var live appsv1.Deployment
+	if err := r.Get(ctx, client.ObjectKeyFromObject(desired), &live); err != nil {
+		t.Fatal(err)
+	}
+	container := live.Spec.Template.Spec.Containers[0]
+	if container.Image != "app:debug" || len(container.Env) != 2 {
+		t.Errorf("expected the drift to be left in place, got %s with %v", container.Image, container.Env)
+	}
+	if desiredReplicas(&live) != 3 {
+		t.Errorf("expected the fields without drift to be applied, got %d replicas", desiredReplicas(&live))
+	}
+	if desiredReplicas(scaled) != 3 || containerImage(scaled.Spec.Template) != "app:debug" {
+		t.Error("expected the applied object to be read back as it is")
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+// Reasons of the events recorded on a Workload for changes of its objects
+const (
+	// reasonCreated is recorded when an object is created for the workload
+	reasonCreated = "Created"
+	// reasonUpdated is recorded when an object of the workload is changed
+	reasonUpdated = "Updated"
+	// reasonApplyFailed is recorded when an object of the workload can not be applied
+	reasonApplyFailed = "ApplyFailed"
+	// reasonPruned is recorded when an object that is no longer desired is deleted
+	reasonPruned = "Pruned"
+	// reasonPruneFailed is recorded when an object that is no longer desired can not be deleted
+	reasonPruneFailed = "PruneFailed"
+	// reasonDeleted is recorded when an object is deleted along with the workload
+	reasonDeleted = "Deleted"
+	// reasonOrphaned is recorded when an object is kept after the workload is deleted
+	reasonOrphaned = "Orphaned"
+	// reasonResized is recorded when a persistent volume claim of the workload is expanded
+	reasonResized = "Resized"
+	// reasonResizeFailed is recorded when a persistent volume claim of the workload can not be expanded
+	reasonResizeFailed = "ResizeFailed"
+	// reasonPermissionDenied is recorded when the workload grants permissions the operator does not allow
+	reasonPermissionDenied = "PermissionDenied"
+	// reasonPullSecretMissing is recorded when an image pull secret of the operator does not exist
+	reasonPullSecretMissing = "PullSecretMissing"
+	// reasonSecretMissing is recorded when a secret referenced by the workload does not exist
+	reasonSecretMissing = "SecretMissing"
+	// reasonFinalizeFailed is recorded when the deletion policy can not be applied
+	reasonFinalizeFailed = "FinalizeFailed"
+	// reasonCanaryStarted is recorded when a canary is started for a new image
+	reasonCanaryStarted = "CanaryStarted"
+	// reasonCanaryProgressed is recorded when traffic is shifted to a canary
+	reasonCanaryProgressed = "CanaryProgressed"
+	// reasonCanaryPromoted is recorded when the image of a canary is rolled out to the workload
+	reasonCanaryPromoted = "CanaryPromoted"
+	// reasonCanaryAborted is recorded when a canary fails its readiness or analysis
+	reasonCanaryAborted = "CanaryAborted"
+	// reasonPromoted is recorded when the preview color of a blue/green workload becomes active
+	reasonPromoted = "Promoted"
+	// reasonRolledBack is recorded when a workload is rolled back to a previous
+	// revision, or a blue/green workload switches back to its previous color
+	reasonRolledBack = "RolledBack"
+	// reasonRollbackFailed is recorded when the revision to roll back to can not be restored
+	reasonRollbackFailed = "RollbackFailed"
+	// reasonPaused is recorded when the reconciliation of the workload is paused
+	reasonPaused = "Paused"
+	// reasonResumed is recorded when the reconciliation of the workload is resumed
+	reasonResumed = "Resumed"
+	// reasonDriftDetected is recorded when fields changed by others are left in place
+	reasonDriftDetected = "DriftDetected"
+	// reasonDriftCorrected is recorded when fields changed by others are reverted
+	reasonDriftCorrected = "DriftCorrected"
+)
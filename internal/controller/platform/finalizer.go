@@ -0,0 +1,130 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// finalizeWorkload applies the deletion policy of the workload to the objects
+// created for it. It runs before the finalizer is removed, so this is also the
+// place to clean up resources that are not garbage collected through owner
+// references.
+func (r *WorkloadReconciler) finalizeWorkload(ctx context.Context, workload *platformv1.Workload) error {
+	log := log.
+		FromContext(ctx)
+
+	for _, child := range ownedObjects(workload) {
+		if retainOnDeletion(workload.Spec.DeletionPolicy, child) {
+			log.Info("orphaning object", "kind", child.Kind, "name", child.Name)
+			if err := r.orphanChild(ctx, workload, child); err != nil {
+				return err
+			}
+			r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonOrphaned, "Orphaned %s %s", child.Kind, child.Name)
+			continue
+		}
+
+		log.Info("deleting object", "kind", child.Kind, "name", child.Name)
+		if err := r.deleteChild(ctx, child); err != nil {
+			return err
+		}
+		r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonDeleted, "Deleted %s %s", child.Kind, child.Name)
+	}
+
+	return nil
+}
+
+// retainOnDeletion reports whether the child is kept in the cluster when the
+// workload is deleted with the given policy.
+func retainOnDeletion(policy platformv1.DeletionPolicy, child corev1.ObjectReference) bool {
+	switch policy {
+	case platformv1.DeletionPolicyOrphan:
+		return true
+	case platformv1.DeletionPolicyRetain:
+		// the ServiceAccount is kept along with the image pull secrets it refers to
+		return child.Kind == "ServiceAccount" || child.Kind == "Secret"
+	default:
+		return false
+	}
+}
+
+// ownedObjects returns the references of the objects created for the workload.
+// The status references are included for workloads that were last reconciled
+// before the inventory was recorded.
+func ownedObjects(workload *platformv1.Workload) []corev1.ObjectReference {
+	refs := append([]corev1.ObjectReference{}, workload.Status.Inventory...)
+	for _, ref := range []corev1.ObjectReference{
+		workload.Status.ServiceAccount,
+		workload.Status.Deployment,
+		workload.Status.Service,
+		workload.Status.Route,
+	} {
+		if ref.Name != "" && !containsObject(refs, ref) {
+			refs = append(refs, ref)
+		}
+	}
+
+	return refs
+}
+
+// objectFromReference returns an unstructured object identifying the referenced
+// object. Unstructured objects are read from the API server instead of the cache.
+func objectFromReference(ref corev1.ObjectReference) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetGroupVersionKind(schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind))
+	obj.SetNamespace(ref.Namespace)
+	obj.SetName(ref.Name)
+
+	return obj
+}
+
+// orphanChild removes the owner reference to the workload from the child, so
+// that it is not garbage collected along with the workload.
+func (r *WorkloadReconciler) orphanChild(ctx context.Context, workload *platformv1.Workload, child corev1.ObjectReference) error {
+	obj := objectFromReference(child)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+
+	patch := client.MergeFrom(obj.DeepCopy())
+
+	var ownerRefs []metav1.OwnerReference
+	for _, ownerRef := range obj.GetOwnerReferences() {
+		if ownerRef.UID != workload.UID {
+			ownerRefs = append(ownerRefs, ownerRef)
+		}
+	}
+	obj.SetOwnerReferences(ownerRefs)
+
+	return r.Patch(ctx, obj, patch)
+}
+
+// deleteChild deletes the child, ignoring children that are already gone.
+func (r *WorkloadReconciler) deleteChild(ctx context.Context, child corev1.ObjectReference) error {
+	obj := objectFromReference(child)
+
+	return client.IgnoreNotFound(r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground)))
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+func TestRetainOnDeletion(t *testing.T) {
+	serviceAccount := corev1.ObjectReference{Kind: "ServiceAccount", Name: "web"}
+	secret := corev1.ObjectReference{Kind: "Secret", Name: "registry"}
+	deployment := corev1.ObjectReference{Kind: "Deployment", Name: "web"}
+
+	tests := []struct {
+		policy platformv1.DeletionPolicy
+		child  corev1.ObjectReference
+		want   bool
+	}{
+		{policy: platformv1.DeletionPolicyDelete, child: serviceAccount},
+		{policy: platformv1.DeletionPolicyDelete, child: deployment},
+		{policy: platformv1.DeletionPolicyOrphan, child: serviceAccount, want: true},
+		{policy: platformv1.DeletionPolicyOrphan, child: deployment, want: true},
+		{policy: platformv1.DeletionPolicyRetain, child: serviceAccount, want: true},
+		{policy: platformv1.DeletionPolicyRetain, child: secret, want: true},
+		{policy: platformv1.DeletionPolicyRetain, child: deployment},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy)+" "+tt.child.Kind, func(t *testing.T) {
+			if got := retainOnDeletion(tt.policy, tt.child); got != tt.want {
+				t.Errorf("retainOnDeletion() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconcileDeletion(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		policy             platformv1.DeletionPolicy
+		wantServiceAccount bool
+		wantDeployment     bool
+	}{
+		{policy: platformv1.DeletionPolicyDelete},
+		{policy: platformv1.DeletionPolicyOrphan, wantServiceAccount: true, wantDeployment: true},
+		{policy: platformv1.DeletionPolicyRetain, wantServiceAccount: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			workload := testWorkload("app:v1")
+			workload.Spec.DeletionPolicy = tt.policy
+			r := newTestReconciler(workload)
+			key := client.ObjectKeyFromObject(workload)
+
+			if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Get(ctx, key, workload); err != nil {
+				t.Fatal(err)
+			}
+			if len(workload.Finalizers) == 0 {
+				t.Fatal("expected the finalizer to be added")
+			}
+
+			if err := r.Delete(ctx, workload); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+				t.Fatal(err)
+			}
+			if err := r.Get(ctx, key, workload); !apierrors.IsNotFound(err) {
+				t.Errorf("expected the workload to be deleted once finalized, got %v", err)
+			}
+
+			expectKept := func(obj client.Object, want bool) {
+				t.Helper()
+				err := r.Get(ctx, key, obj)
+				switch {
+				case want && err != nil:
+					t.Errorf("expected the %T to be kept, got %v", obj, err)
+				case want && metav1.GetControllerOf(obj) != nil:
+					t.Errorf("expected the owner reference of the %T to be removed", obj)
+				case !want && !apierrors.IsNotFound(err):
+					t.Errorf("expected the %T to be deleted, got %v", obj, err)
+				}
+			}
+			expectKept(&corev1.ServiceAccount{}, tt.wantServiceAccount)
+			expectKept(&appsv1.Deployment{}, tt.wantDeployment)
+		})
+	}
+}
@@ -0,0 +1,235 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+// The unit tests of the reconciler use plain Go tests with the fake client of
+// controller-runtime instead of Ginkgo specs against the envtest API server of
+// suite_test.go: they run with go test alone, without the envtest binaries
+// installed by make test, so every building block of Reconcile can be tested
+// on its own. The fixtures below are shared by all of them.
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// newTestReconciler returns a reconciler with the default operator
+// configuration and a fake client holding the objects.
+func newTestReconciler(objs ...client.Object) *WorkloadReconciler {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(platformv1.AddToScheme(scheme))
+
+	var config configv1alpha1.OperatorConfig
+	configv1alpha1.SetDefaults_Configuration(&config)
+
+	// the fake client patches existing objects only, applied objects are created
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).WithStatusSubresource(&platformv1.Workload{}).Build()
+	applyCreates := interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			err := c.Patch(ctx, obj, patch, opts...)
+			if patch.Type() == types.ApplyPatchType && apierrors.IsNotFound(err) {
+				return c.Create(ctx, obj)
+			}
+			return err
+		},
+	}
+
+	return &WorkloadReconciler{
+		Client:   interceptor.NewClient(c, applyCreates),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(100),
+		Config:   config,
+	}
+}
+
+// testWorkload returns the workload "web" running 4 replicas of the image.
+func testWorkload(image string) *platformv1.Workload {
+	return &platformv1.Workload{
+		TypeMeta:   metav1.TypeMeta{APIVersion: platformv1.GroupVersion.String(), Kind: "Workload"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default", UID: types.UID("web-uid")},
+		Spec: platformv1.WorkloadSpec{
+			Replicas:  pointer.Int32(4),
+			Container: &platformv1.ContainerSpec{Image: image},
+		},
+	}
+}
+
+// canaryWorkload returns the workload "web" rolling out the image as a canary
+// with one step at half of the traffic.
+func canaryWorkload(image string, analysis *platformv1.CanaryAnalysis) *platformv1.Workload {
+	workload := testWorkload(image)
+	workload.Spec.Rollout = &platformv1.RolloutSpec{
+		Strategy: platformv1.RolloutStrategyCanary,
+		Canary: &platformv1.CanarySpec{
+			Steps:    []platformv1.CanaryStep{{Weight: 50}},
+			Analysis: analysis,
+		},
+	}
+
+	return workload
+}
+
+// blueGreenWorkload returns the workload "web" rolling out the image in blue
+// and green Deployments.
+func blueGreenWorkload(image string) *platformv1.Workload {
+	workload := testWorkload(image)
+	workload.Spec.Rollout = &platformv1.RolloutSpec{Strategy: platformv1.RolloutStrategyBlueGreen}
+
+	return workload
+}
+
+// testDeployment returns a Deployment of the workload "web" running the image.
+func testDeployment(name, image string, replicas int32) *appsv1.Deployment {
+	labels := map[string]string{workloadLabel: "web"}
+
+	return &appsv1.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: pointer.Int32(replicas),
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: workloadContainerName, Image: image}},
+				},
+			},
+		},
+	}
+}
+
+// testPodTemplate returns the pod template of the workload "web" running the image.
+func testPodTemplate(image string) corev1.PodTemplateSpec {
+	return testDeployment("web", image, 0).Spec.Template
+}
+
+// colorDeployment returns the rolled out Deployment of the color of the
+// workload, running the image and marked active or not.
+func colorDeployment(t *testing.T, workload *platformv1.Workload, color platformv1.DeploymentColor, image string, active bool) *appsv1.Deployment {
+	t.Helper()
+
+	template := testPodTemplate(image)
+	hash, err := specHash(template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deployment, err := newTestReconciler().desiredColorDeployment(*workload, color, template, hash, workload.Spec.Replicas)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active {
+		deployment.Annotations[activeAnnotation] = "true"
+	}
+
+	return rolledOut(deployment)
+}
+
+// driftDeployment returns the Deployment "web" as applied by the operator,
+// with a label and the env vars.
+func driftDeployment(env ...corev1.EnvVar) *appsv1.Deployment {
+	deployment := testDeployment("web", "app:v1", 2)
+	deployment.Labels = map[string]string{"team": "a"}
+	deployment.Spec.Template.Spec.Containers[0].Env = env
+
+	return deployment
+}
+
+// managedFieldsEntry returns the managed fields entry of an update by the manager.
+func managedFieldsEntry(manager, subresource, fields string) metav1.ManagedFieldsEntry {
+	return metav1.ManagedFieldsEntry{
+		Manager:     manager,
+		Operation:   metav1.ManagedFieldsOperationUpdate,
+		APIVersion:  appsv1.SchemeGroupVersion.String(),
+		FieldsType:  "FieldsV1",
+		FieldsV1:    &metav1.FieldsV1{Raw: []byte(fields)},
+		Subresource: subresource,
+	}
+}
+
+// editedDeployment returns the Deployment with the image changed and an env
+// var added by kubectl edit.
+func editedDeployment(desired *appsv1.Deployment) *appsv1.Deployment {
+	live := desired.DeepCopy()
+	live.ResourceVersion = "1"
+	live.Spec.Template.Spec.Containers[0].Image = "app:debug"
+	live.Spec.Template.Spec.Containers[0].Env = append(live.Spec.Template.Spec.Containers[0].Env,
+		corev1.EnvVar{Name: "DEBUG", Value: "true"})
+	live.Annotations = map[string]string{"deployment.kubernetes.io/revision": "2"}
+	live.ManagedFields = []metav1.ManagedFieldsEntry{
+		managedFieldsEntry(fieldOwner, "", `{"f:spec":{"f:replicas":{}}}`),
+		managedFieldsEntry("kubectl-edit", "", `{"f:spec":{"f:template":{"f:spec":{"f:containers":{`+
+			`"k:{\"name\":\"`+workloadContainerName+`\"}":{"f:image":{},"f:env":{".":{},`+
+			`"k:{\"name\":\"DEBUG\"}":{".":{},"f:name":{},"f:value":{}}}}}}}}}`),
+		managedFieldsEntry("kube-controller-manager", "", `{"f:metadata":{"f:annotations":{"f:deployment.kubernetes.io/revision":{}}}}`),
+		managedFieldsEntry("kube-controller-manager", "status", `{"f:status":{"f:replicas":{}}}`),
+	}
+
+	return live
+}
+
+// rolledOut returns the Deployment as rolled out by the deployment controller.
+func rolledOut(deployment *appsv1.Deployment) *appsv1.Deployment {
+	ready := deployment.DeepCopy()
+	replicas := desiredReplicas(ready)
+	ready.Generation = 1
+	ready.Status = appsv1.DeploymentStatus{
+		ObservedGeneration: 1,
+		Replicas:           replicas,
+		UpdatedReplicas:    replicas,
+		ReadyReplicas:      replicas,
+		AvailableReplicas:  replicas,
+	}
+
+	return ready
+}
+
+// expectEvent fails the test when no event with the reason was recorded.
+func expectEvent(t *testing.T, r *WorkloadReconciler, reason string) {
+	t.Helper()
+
+	recorder := r.Recorder.(*record.FakeRecorder)
+	for {
+		select {
+		case event := <-recorder.Events:
+			if strings.Contains(event, " "+reason+" ") {
+				return
+			}
+		default:
+			t.Errorf("expected a %s event", reason)
+			return
+		}
+	}
+}
@@ -17,13 +17,64 @@ limitations under the License.
 package controller
 
 import (
+	"fmt"
+
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
 	platformv1 "mydev.org/platform-operator/api/platform/v1"
 )
 
+// httpRouteGVK is the Gateway API HTTPRoute kind. The Gateway API types are
+// handled as unstructured objects so the CRDs are only needed in clusters
+// that use them.
+var httpRouteGVK = schema.GroupVersionKind{Group: "gateway.networking.k8s.io", Version: "v1beta1", Kind: "HTTPRoute"}
+
+const (
+	// workloadLabel is set on every pod of a workload and used as its selector
+	workloadLabel = "platform.mydev.org/workload"
+
+	// workloadContainerName is the name of the container running the workload image
+	workloadContainerName = "app"
+)
+
+// selectorLabels returns the labels used to select the pods of a workload.
+func selectorLabels(workload platformv1.Workload) map[string]string {
+	return map[string]string{
+		workloadLabel: workload.Name,
+	}
+}
+
+// runsPods reports whether the workload has a container to run in pods.
+func runsPods(workload platformv1.Workload) bool {
+	return workload.Spec.Container != nil
+}
+
+// podLabels returns the labels of the pods of a workload.
+func podLabels(workload platformv1.Workload) map[string]string {
+	labels := selectorLabels(workload)
+
+	// the Azure workload identity webhook only mutates labeled pods
+	if workload.Spec.Identity != nil && workload.Spec.Identity.Provider == platformv1.IdentityProviderAzure {
+		labels[azureUseLabel] = "true"
+	}
+
+	// the pods of a canary are told apart from the stable pods
+	if usesCanary(workload) {
+		labels[trackLabel] = trackStable
+	}
+
+	return labels
+}
+
 func (r *WorkloadReconciler) desiredServiceAccount(workload platformv1.Workload) (corev1.ServiceAccount, error) {
 	svcAccountName := workload.Name
 	if workload.Spec.ServiceAccountName != "" {
@@ -36,9 +87,16 @@ func (r *WorkloadReconciler) desiredServiceAccount(workload platformv1.Workload)
 			Name:      svcAccountName,
 			Namespace: workload.Namespace,
 		},
-		ImagePullSecrets: []corev1.LocalObjectReference{
-			{Name: "imagepullsecret-patcher"},
-		},
+		ImagePullSecrets: r.imagePullSecrets(workload),
+	}
+
+	// bind the service account to the cloud identity of the workload
+	if workload.Spec.Identity != nil {
+		principal, err := r.identityPrincipal(workload)
+		if err != nil {
+			return svcAccount, err
+		}
+		svcAccount.Annotations, svcAccount.Labels = r.identityMetadata(workload.Spec.Identity.Provider, principal)
 	}
 
 	// always set the controller reference so that we know which object owns this.
@@ -48,3 +106,309 @@ func (r *WorkloadReconciler) desiredServiceAccount(workload platformv1.Workload)
 
 	return svcAccount, nil
 }
+
+// desiredPodTemplate returns the pod template shared by the Deployment, Job and
+// CronJob of the workload. The workload must have a container.
+func (r *WorkloadReconciler) desiredPodTemplate(workload platformv1.Workload, svcAccount corev1.ServiceAccount, configHash string) corev1.PodTemplateSpec {
+	container := *workload.Spec.Container
+
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: podLabels(workload),
+		},
+		Spec: corev1.PodSpec{
+			ServiceAccountName:            svcAccount.Name,
+			TerminationGracePeriodSeconds: r.terminationGracePeriodSeconds(workload),
+			Affinity:                      r.podAffinity(workload),
+			TopologySpreadConstraints:     r.topologySpreadConstraints(workload),
+			Containers: []corev1.Container{
+				{
+					Name:      workloadContainerName,
+					Image:     container.Image,
+					Command:   container.Command,
+					Args:      container.Args,
+					Env:       container.Env,
+					Ports:     container.Ports,
+					Resources: container.Resources,
+				},
+			},
+		},
+	}
+
+	r.applyProbeDefaults(workload, &template.Spec.Containers[0])
+	injectConfig(workload, &template, configHash)
+
+	return template
+}
+
+func (r *WorkloadReconciler) desiredDeployment(workload platformv1.Workload, podTemplate corev1.PodTemplateSpec) (appsv1.Deployment, error) {
+	// the replicas of an autoscaled workload are owned by the HorizontalPodAutoscaler
+	replicas := workload.Spec.Replicas
+	if workload.Spec.Autoscaling != nil {
+		replicas = nil
+	}
+
+	deployment := appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workload.Name,
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selectorLabels(workload),
+			},
+			Template: podTemplate,
+		},
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, &deployment, r.Scheme); err != nil {
+		return deployment, err
+	}
+
+	return deployment, nil
+}
+
+func (r *WorkloadReconciler) desiredService(workload platformv1.Workload) (corev1.Service, error) {
+	ports := make([]corev1.ServicePort, 0, len(workload.Spec.Service.Ports))
+	for _, port := range workload.Spec.Service.Ports {
+		targetPort := port.TargetPort
+		if targetPort.IntVal == 0 && targetPort.StrVal == "" {
+			targetPort = intstr.FromInt(int(port.Port))
+		}
+
+		ports = append(ports, corev1.ServicePort{
+			Name:       port.Name,
+			Protocol:   port.Protocol,
+			Port:       port.Port,
+			TargetPort: targetPort,
+			NodePort:   port.NodePort,
+		})
+	}
+
+	// canary pods with weighted routing and the inactive color of blue/green
+	// workloads are only reached through their own Service, see stableTracked
+	selector := selectorLabels(workload)
+	if r.weightedRouting(workload) {
+		selector[trackLabel] = trackStable
+	}
+	if usesBlueGreen(workload) {
+		selector[colorLabel] = string(activeColor(workload))
+	}
+
+	service := corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workload.Name,
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: corev1.ServiceSpec{
+			Type:     corev1.ServiceTypeClusterIP,
+			Selector: selector,
+			Ports:    ports,
+		},
+	}
+
+	switch workload.Spec.Service.Type {
+	case platformv1.ServiceTypeNodePort:
+		service.Spec.Type = corev1.ServiceTypeNodePort
+	case platformv1.ServiceTypeLoadBalancer:
+		service.Spec.Type = corev1.ServiceTypeLoadBalancer
+	case platformv1.ServiceTypeHeadless:
+		service.Spec.ClusterIP = corev1.ClusterIPNone
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, &service, r.Scheme); err != nil {
+		return service, err
+	}
+
+	return service, nil
+}
+
+// desiredRoute returns the Ingress or HTTPRoute for the workload, depending on
+// the routing mode of the operator.
+func (r *WorkloadReconciler) desiredRoute(workload platformv1.Workload) (client.Object, error) {
+	if workload.Spec.Service == nil || len(workload.Spec.Service.Ports) == 0 {
+		return nil, fmt.Errorf("ingress of workload %s requires a service port", workload.Name)
+	}
+
+	switch r.Config.Routing.Mode {
+	case configv1alpha1.RoutingModeGatewayAPI:
+		route, err := r.desiredHTTPRoute(workload)
+		return &route, err
+	default:
+		ingress, err := r.desiredIngress(workload)
+		return &ingress, err
+	}
+}
+
+// ingressPaths returns the paths routed to the workload, defaulting to a single
+// prefix path on the first Service port.
+func ingressPaths(workload platformv1.Workload) []platformv1.IngressPath {
+	paths := workload.Spec.Ingress.Paths
+	if len(paths) == 0 {
+		paths = []platformv1.IngressPath{{Path: "/"}}
+	}
+
+	defaulted := make([]platformv1.IngressPath, 0, len(paths))
+	for _, path := range paths {
+		if path.PathType == "" {
+			path.PathType = platformv1.PathTypePrefix
+		}
+		if path.Port == "" {
+			path.Port = workload.Spec.Service.Ports[0].Name
+		}
+		defaulted = append(defaulted, path)
+	}
+
+	return defaulted
+}
+
+func (r *WorkloadReconciler) desiredIngress(workload platformv1.Workload) (networkingv1.Ingress, error) {
+	var httpPaths []networkingv1.HTTPIngressPath
+	for _, path := range ingressPaths(workload) {
+		pathType := networkingv1.PathTypePrefix
+		if path.PathType == platformv1.PathTypeExact {
+			pathType = networkingv1.PathTypeExact
+		}
+
+		httpPaths = append(httpPaths, networkingv1.HTTPIngressPath{
+			Path:     path.Path,
+			PathType: &pathType,
+			Backend: networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: workload.Name,
+					Port: networkingv1.ServiceBackendPort{Name: path.Port},
+				},
+			},
+		})
+	}
+
+	var rules []networkingv1.IngressRule
+	for _, host := range workload.Spec.Ingress.Hosts {
+		rules = append(rules, networkingv1.IngressRule{
+			Host: host,
+			IngressRuleValue: networkingv1.IngressRuleValue{
+				HTTP: &networkingv1.HTTPIngressRuleValue{Paths: httpPaths},
+			},
+		})
+	}
+
+	ingress := networkingv1.Ingress{
+		TypeMeta: metav1.TypeMeta{APIVersion: networkingv1.SchemeGroupVersion.String(), Kind: "Ingress"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workload.Name,
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: networkingv1.IngressSpec{
+			IngressClassName: r.Config.Routing.IngressClassName,
+			Rules:            rules,
+		},
+	}
+
+	if tls := workload.Spec.Ingress.TLS; tls != nil {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{
+			{Hosts: workload.Spec.Ingress.Hosts, SecretName: tls.SecretName},
+		}
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, &ingress, r.Scheme); err != nil {
+		return ingress, err
+	}
+
+	return ingress, nil
+}
+
+func (r *WorkloadReconciler) desiredHTTPRoute(workload platformv1.Workload) (unstructured.Unstructured, error) {
+	route := unstructured.Unstructured{}
+	route.SetGroupVersionKind(httpRouteGVK)
+	route.SetName(workload.Name)
+	route.SetNamespace(workload.Namespace)
+	route.SetLabels(selectorLabels(workload))
+
+	gateway := r.Config.Routing.Gateway
+	if gateway == nil {
+		return route, fmt.Errorf("routing mode %s requires a gateway in the operator configuration", configv1alpha1.RoutingModeGatewayAPI)
+	}
+
+	parentRef := map[string]interface{}{
+		"name":      gateway.Name,
+		"namespace": gateway.Namespace,
+	}
+	sectionName := gateway.SectionName
+	if workload.Spec.Ingress.TLS != nil && gateway.TLSSectionName != "" {
+		sectionName = gateway.TLSSectionName
+	}
+	if sectionName != "" {
+		parentRef["sectionName"] = sectionName
+	}
+
+	hostnames := make([]interface{}, 0, len(workload.Spec.Ingress.Hosts))
+	for _, host := range workload.Spec.Ingress.Hosts {
+		hostnames = append(hostnames, host)
+	}
+
+	var rules []interface{}
+	for _, path := range ingressPaths(workload) {
+		port, err := servicePort(workload, path.Port)
+		if err != nil {
+			return route, err
+		}
+
+		matchType := "PathPrefix"
+		if path.PathType == platformv1.PathTypeExact {
+			matchType = "Exact"
+		}
+
+		backendRefs := []interface{}{
+			map[string]interface{}{"name": workload.Name, "port": int64(port.Port)},
+		}
+		if canaryActive(workload) && r.weightedRouting(workload) && workload.Status.Canary.Weight > 0 {
+			weight := int64(workload.Status.Canary.Weight)
+			backendRefs = []interface{}{
+				map[string]interface{}{"name": workload.Name, "port": int64(port.Port), "weight": 100 - weight},
+				map[string]interface{}{"name": workload.Name + canarySuffix, "port": int64(port.Port), "weight": weight},
+			}
+		}
+
+		rules = append(rules, map[string]interface{}{
+			"matches": []interface{}{
+				map[string]interface{}{
+					"path": map[string]interface{}{"type": matchType, "value": path.Path},
+				},
+			},
+			"backendRefs": backendRefs,
+		})
+	}
+
+	route.Object["spec"] = map[string]interface{}{
+		"parentRefs": []interface{}{parentRef},
+		"hostnames":  hostnames,
+		"rules":      rules,
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, &route, r.Scheme); err != nil {
+		return route, err
+	}
+
+	return route, nil
+}
+
+// servicePort returns the Service port of the workload with the given name.
+func servicePort(workload platformv1.Workload, name string) (platformv1.ServicePort, error) {
+	for _, port := range workload.Spec.Service.Ports {
+		if port.Name == name {
+			return port, nil
+		}
+	}
+
+	return platformv1.ServicePort{}, fmt.Errorf("service of workload %s has no port named %s", workload.Name, name)
+}
@@ -0,0 +1,262 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// expectOwned fails the test unless the object is controlled by the workload "web".
+func expectOwned(t *testing.T, obj metav1.Object) {
+	t.Helper()
+
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil || owner.Kind != "Workload" || owner.Name != "web" || owner.UID != "web-uid" {
+		t.Errorf("expected %s to be controlled by the workload web, got %v", obj.GetName(), owner)
+	}
+}
+
+func TestDesiredDeployment(t *testing.T) {
+	r := newTestReconciler()
+	workload := testWorkload("app:v1")
+	workload.Spec.Container.Command = []string{"/server"}
+	workload.Spec.Container.Args = []string{"--verbose"}
+	workload.Spec.Container.Env = []corev1.EnvVar{{Name: "MODE", Value: "prod"}}
+
+	svcAccount, err := r.desiredServiceAccount(*workload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := r.desiredPodTemplate(*workload, svcAccount, "")
+	deployment, err := r.desiredDeployment(*workload, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if deployment.Name != "web" || deployment.Namespace != "default" {
+		t.Errorf("expected the Deployment default/web, got %s/%s", deployment.Namespace, deployment.Name)
+	}
+	expectOwned(t, &deployment)
+	if desiredReplicas(&deployment) != 4 {
+		t.Errorf("expected 4 replicas, got %d", desiredReplicas(&deployment))
+	}
+	if want := map[string]string{workloadLabel: "web"}; !reflect.DeepEqual(deployment.Spec.Selector.MatchLabels, want) ||
+		!reflect.DeepEqual(deployment.Spec.Template.Labels, want) {
+		t.Errorf("expected the pods to be selected by %v, got %v", want, deployment.Spec.Selector.MatchLabels)
+	}
+
+	spec := deployment.Spec.Template.Spec
+	if spec.ServiceAccountName != "web" {
+		t.Errorf("expected the pods to run as web, got %s", spec.ServiceAccountName)
+	}
+	if len(spec.Containers) != 1 {
+		t.Fatalf("expected a single container, got %d", len(spec.Containers))
+	}
+	container := spec.Containers[0]
+	if container.Name != workloadContainerName || container.Image != "app:v1" ||
+		!reflect.DeepEqual(container.Command, []string{"/server"}) || !reflect.DeepEqual(container.Args, []string{"--verbose"}) ||
+		!reflect.DeepEqual(container.Env, workload.Spec.Container.Env) {
+		t.Errorf("expected the container of the workload spec, got %v", container)
+	}
+
+	// the replicas of an autoscaled workload are left to the HorizontalPodAutoscaler
+	workload.Spec.Autoscaling = &platformv1.AutoscalingSpec{MaxReplicas: 10}
+	if autoscaled, err := r.desiredDeployment(*workload, template); err != nil || autoscaled.Spec.Replicas != nil {
+		t.Errorf("expected no replicas for an autoscaled workload, got %v (%v)", autoscaled.Spec.Replicas, err)
+	}
+}
+
+func TestReconcileWithoutContainer(t *testing.T) {
+	ctx := context.Background()
+	workload := testWorkload("")
+	workload.Spec.Container = nil
+	r := newTestReconciler(workload)
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(workload)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(workload), &corev1.ServiceAccount{}); err != nil {
+		t.Errorf("expected the ServiceAccount to be applied, got %v", err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(workload), &appsv1.Deployment{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected no Deployment without a container, got %v", err)
+	}
+}
+
+func TestDesiredService(t *testing.T) {
+	ports := []platformv1.ServicePort{
+		{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80, TargetPort: intstr.FromString("http")},
+		{Name: "metrics", Protocol: corev1.ProtocolTCP, Port: 9090, NodePort: 30090},
+	}
+	wantPorts := []corev1.ServicePort{
+		{Name: "http", Protocol: corev1.ProtocolTCP, Port: 80, TargetPort: intstr.FromString("http")},
+		{Name: "metrics", Protocol: corev1.ProtocolTCP, Port: 9090, TargetPort: intstr.FromInt(9090), NodePort: 30090},
+	}
+
+	tests := []struct {
+		serviceType   platformv1.ServiceType
+		wantType      corev1.ServiceType
+		wantClusterIP string
+	}{
+		{serviceType: platformv1.ServiceTypeClusterIP, wantType: corev1.ServiceTypeClusterIP},
+		{serviceType: platformv1.ServiceTypeNodePort, wantType: corev1.ServiceTypeNodePort},
+		{serviceType: platformv1.ServiceTypeLoadBalancer, wantType: corev1.ServiceTypeLoadBalancer},
+		{serviceType: platformv1.ServiceTypeHeadless, wantType: corev1.ServiceTypeClusterIP, wantClusterIP: corev1.ClusterIPNone},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.serviceType), func(t *testing.T) {
+			workload := testWorkload("app:v1")
+			workload.Spec.Service = &platformv1.ServiceSpec{Type: tt.serviceType, Ports: ports}
+
+			service, err := newTestReconciler().desiredService(*workload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if service.Name != "web" {
+				t.Errorf("expected the Service web, got %s", service.Name)
+			}
+			expectOwned(t, &service)
+			if service.Spec.Type != tt.wantType || service.Spec.ClusterIP != tt.wantClusterIP {
+				t.Errorf("expected a %s Service with cluster IP %q, got %s with %q", tt.wantType, tt.wantClusterIP,
+					service.Spec.Type, service.Spec.ClusterIP)
+			}
+			if want := map[string]string{workloadLabel: "web"}; !reflect.DeepEqual(service.Spec.Selector, want) {
+				t.Errorf("expected the selector %v, got %v", want, service.Spec.Selector)
+			}
+			if !reflect.DeepEqual(service.Spec.Ports, wantPorts) {
+				t.Errorf("expected the ports %v, got %v", wantPorts, service.Spec.Ports)
+			}
+		})
+	}
+}
+
+// routedWorkload returns the workload "web" reachable on example.com, with the
+// Service ports http and admin.
+func routedWorkload() *platformv1.Workload {
+	workload := testWorkload("app:v1")
+	workload.Spec.Service = &platformv1.ServiceSpec{Ports: []platformv1.ServicePort{
+		{Name: "http", Port: 80},
+		{Name: "admin", Port: 8081},
+	}}
+	workload.Spec.Ingress = &platformv1.IngressSpec{
+		Hosts: []string{"example.com"},
+		TLS:   &platformv1.IngressTLS{SecretName: "example-tls"},
+	}
+
+	return workload
+}
+
+func TestDesiredRouteIngress(t *testing.T) {
+	r := newTestReconciler()
+	r.Config.Routing.IngressClassName = pointer.String("nginx")
+	workload := routedWorkload()
+	workload.Spec.Ingress.Paths = []platformv1.IngressPath{
+		{Path: "/"},
+		{Path: "/admin", PathType: platformv1.PathTypeExact, Port: "admin"},
+	}
+
+	route, err := r.desiredRoute(*workload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ingress, ok := route.(*networkingv1.Ingress)
+	if !ok {
+		t.Fatalf("expected an Ingress, got %T", route)
+	}
+	expectOwned(t, ingress)
+	if ingress.Spec.IngressClassName == nil || *ingress.Spec.IngressClassName != "nginx" {
+		t.Errorf("expected the IngressClass nginx, got %v", ingress.Spec.IngressClassName)
+	}
+	if len(ingress.Spec.TLS) != 1 || ingress.Spec.TLS[0].SecretName != "example-tls" ||
+		!reflect.DeepEqual(ingress.Spec.TLS[0].Hosts, []string{"example.com"}) {
+		t.Errorf("expected TLS for example.com with example-tls, got %v", ingress.Spec.TLS)
+	}
+	if len(ingress.Spec.Rules) != 1 || ingress.Spec.Rules[0].Host != "example.com" {
+		t.Fatalf("expected a rule for example.com, got %v", ingress.Spec.Rules)
+	}
+
+	var got []string
+	for _, path := range ingress.Spec.Rules[0].HTTP.Paths {
+		got = append(got, path.Path+" "+string(*path.PathType)+" "+path.Backend.Service.Name+":"+path.Backend.Service.Port.Name)
+	}
+	if want := []string{"/ Prefix web:http", "/admin Exact web:admin"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the paths %v, got %v", want, got)
+	}
+}
+
+func TestDesiredRouteHTTPRoute(t *testing.T) {
+	r := newTestReconciler()
+	r.Config.Routing.Mode = configv1alpha1.RoutingModeGatewayAPI
+	workload := routedWorkload()
+	workload.Spec.Ingress.Paths = []platformv1.IngressPath{{Path: "/admin", PathType: platformv1.PathTypeExact, Port: "admin"}}
+
+	if _, err := r.desiredRoute(*workload); err == nil {
+		t.Error("expected an error without a gateway")
+	}
+
+	r.Config.Routing.Gateway = &configv1alpha1.GatewayReference{Name: "public", Namespace: "gateways", SectionName: "http", TLSSectionName: "https"}
+	obj, err := r.desiredRoute(*workload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	route, ok := obj.(*unstructured.Unstructured)
+	if !ok || route.GroupVersionKind() != httpRouteGVK {
+		t.Fatalf("expected an HTTPRoute, got %v", obj.GetObjectKind().GroupVersionKind())
+	}
+	expectOwned(t, route)
+
+	parentRefs, _, _ := unstructured.NestedSlice(route.Object, "spec", "parentRefs")
+	want := []interface{}{map[string]interface{}{"name": "public", "namespace": "gateways", "sectionName": "https"}}
+	if !reflect.DeepEqual(parentRefs, want) {
+		t.Errorf("expected the TLS listener of the gateway, got %v", parentRefs)
+	}
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	wantRules := []interface{}{map[string]interface{}{
+		"matches":     []interface{}{map[string]interface{}{"path": map[string]interface{}{"type": "Exact", "value": "/admin"}}},
+		"backendRefs": []interface{}{map[string]interface{}{"name": "web", "port": int64(8081)}},
+	}}
+	if !reflect.DeepEqual(rules, wantRules) {
+		t.Errorf("expected the rules %v, got %v", wantRules, rules)
+	}
+
+	// paths must route to a port of the Service
+	workload.Spec.Ingress.Paths[0].Port = "grpc"
+	if _, err := r.desiredRoute(*workload); err == nil {
+		t.Error("expected an error for an unknown Service port")
+	}
+	workload.Spec.Service = nil
+	if _, err := r.desiredRoute(*workload); err == nil {
+		t.Error("expected an error without a Service")
+	}
+}
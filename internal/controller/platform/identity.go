@@ -0,0 +1,137 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// Annotations and labels of the workload identity webhooks of the cloud providers
+const (
+	awsRoleARNAnnotation              = "eks.amazonaws.com/role-arn"
+	awsSTSRegionalEndpointsAnnotation = "eks.amazonaws.com/sts-regional-endpoints"
+	gcpServiceAccountAnnotation       = "iam.gke.io/gcp-service-account"
+	azureClientIDAnnotation           = "azure.workload.identity/client-id"
+	azureTenantIDAnnotation           = "azure.workload.identity/tenant-id"
+	azureUseLabel                     = "azure.workload.identity/use"
+)
+
+// identityPrincipal returns the cloud principal of the workload identity.
+func (r *WorkloadReconciler) identityPrincipal(workload platformv1.Workload) (string, error) {
+	identity := workload.Spec.Identity
+	config := r.Config.Identity
+
+	switch identity.Provider {
+	case platformv1.IdentityProviderAWS:
+		if strings.HasPrefix(identity.Role, "arn:") {
+			return identity.Role, nil
+		}
+		if config.AWS == nil || config.AWS.AccountID == "" {
+			return "", fmt.Errorf("no AWS account is configured for the IAM role of workload %s", workload.Name)
+		}
+		name, err := r.identityName(workload, config.AWS.RoleNameTemplate)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("arn:aws:iam::%s:role/%s", config.AWS.AccountID, name), nil
+	case platformv1.IdentityProviderGCP:
+		if strings.Contains(identity.Role, "@") {
+			return identity.Role, nil
+		}
+		if config.GCP == nil || config.GCP.ProjectID == "" {
+			return "", fmt.Errorf("no GCP project is configured for the service account of workload %s", workload.Name)
+		}
+		name, err := r.identityName(workload, config.GCP.ServiceAccountTemplate)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s@%s.iam.gserviceaccount.com", name, config.GCP.ProjectID), nil
+	case platformv1.IdentityProviderAzure:
+		if identity.Role == "" {
+			return "", fmt.Errorf("no managed identity client ID is set for workload %s", workload.Name)
+		}
+		return identity.Role, nil
+	default:
+		return "", fmt.Errorf("unknown identity provider %q of workload %s", identity.Provider, workload.Name)
+	}
+}
+
+// identityName returns the role of the workload identity, or executes the
+// naming template when the workload has no role.
+func (r *WorkloadReconciler) identityName(workload platformv1.Workload, nameTemplate string) (string, error) {
+	if workload.Spec.Identity.Role != "" {
+		return workload.Spec.Identity.Role, nil
+	}
+
+	tmpl, err := template.New("identity").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid identity naming template: %w", err)
+	}
+
+	var name strings.Builder
+	data := struct{ ClusterName, Namespace, Name string }{r.Config.ClusterName, workload.Namespace, workload.Name}
+	if err := tmpl.Execute(&name, data); err != nil {
+		return "", fmt.Errorf("invalid identity naming template: %w", err)
+	}
+
+	return name.String(), nil
+}
+
+// identityMetadata returns the annotations and labels of the ServiceAccount
+// bound to the cloud principal.
+func (r *WorkloadReconciler) identityMetadata(provider platformv1.IdentityProvider, principal string) (map[string]string, map[string]string) {
+	switch provider {
+	case platformv1.IdentityProviderAWS:
+		annotations := map[string]string{awsRoleARNAnnotation: principal}
+		if r.Config.Identity.AWS != nil && r.Config.Identity.AWS.STSRegionalEndpoints {
+			annotations[awsSTSRegionalEndpointsAnnotation] = "true"
+		}
+		return annotations, nil
+	case platformv1.IdentityProviderGCP:
+		return map[string]string{gcpServiceAccountAnnotation: principal}, nil
+	case platformv1.IdentityProviderAzure:
+		annotations := map[string]string{azureClientIDAnnotation: principal}
+		if r.Config.Identity.Azure != nil && r.Config.Identity.Azure.TenantID != "" {
+			annotations[azureTenantIDAnnotation] = r.Config.Identity.Azure.TenantID
+		}
+		return annotations, map[string]string{azureUseLabel: "true"}
+	default:
+		return nil, nil
+	}
+}
+
+// identityStatus returns the values needed to trust the ServiceAccount of the workload.
+func (r *WorkloadReconciler) identityStatus(workload platformv1.Workload, namespace, name string) (*platformv1.IdentityStatus, error) {
+	if workload.Spec.Identity == nil {
+		return nil, nil
+	}
+
+	principal, err := r.identityPrincipal(workload)
+	if err != nil {
+		return nil, err
+	}
+
+	return &platformv1.IdentityStatus{
+		Principal: principal,
+		Issuer:    r.Config.Identity.OIDCIssuer,
+		Subject:   fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name),
+	}, nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// identityReconciler returns a reconciler with the cloud identities configured
+// for the cluster "prod".
+func identityReconciler() *WorkloadReconciler {
+	r := newTestReconciler()
+	r.Config.ClusterName = "prod"
+	r.Config.Identity = configv1alpha1.Identity{
+		OIDCIssuer: "https://oidc.example.com",
+		AWS: &configv1alpha1.AWSIdentity{
+			AccountID:            "123456789012",
+			RoleNameTemplate:     configv1alpha1.DefaultAWSRoleNameTemplate,
+			STSRegionalEndpoints: true,
+		},
+		GCP: &configv1alpha1.GCPIdentity{
+			ProjectID:              "platform",
+			ServiceAccountTemplate: configv1alpha1.DefaultGCPServiceAccountTemplate,
+		},
+		Azure: &configv1alpha1.AzureIdentity{TenantID: "tenant"},
+	}
+
+	return r
+}
+
+func TestIdentityPrincipal(t *testing.T) {
+	tests := []struct {
+		name     string
+		identity platformv1.IdentitySpec
+		config   func(*WorkloadReconciler)
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "AWS role of the template",
+			identity: platformv1.IdentitySpec{Provider: platformv1.IdentityProviderAWS},
+			want:     "arn:aws:iam::123456789012:role/prod-default-web",
+		},
+		{
+			name:     "AWS role name",
+			identity: platformv1.IdentitySpec{Provider: platformv1.IdentityProviderAWS, Role: "web-reader"},
+			want:     "arn:aws:iam::123456789012:role/web-reader",
+		},
+		{
+			name:     "AWS role ARN",
+			identity: platformv1.IdentitySpec{Provider: platformv1.IdentityProviderAWS, Role: "arn:aws:iam::210987654321:role/shared"},
+			config:   func(r *WorkloadReconciler) { r.Config.Identity.AWS = nil },
+			want:     "arn:aws:iam::210987654321:role/shared",
+		},
+		{
+			name:     "AWS without account",
+			identity: platformv1.IdentitySpec{Provider: platformv1.IdentityProviderAWS},
+			config:   func(r *WorkloadReconciler) { r.Config.Identity.AWS = nil },
+			wantErr:  true,
+		},
+		{
+			name:     "invalid template",
+			identity: platformv1.IdentitySpec{Provider: platformv1.IdentityProviderAWS},
+			config:   func(r *WorkloadReconciler) { r.Config.Identity.AWS.RoleNameTemplate = "{{.Team}}" },
+			wantErr:  true,
+		},
+		{
+			name:     "GCP service account of the template",
+			identity: platformv1.IdentitySpec{Provider: platformv1.IdentityProviderGCP},
+			want:     "default-web@platform.iam.gserviceaccount.com",
+		},
+		{
+			name:     "GCP service account email",
+			identity: platformv1.IdentitySpec{Provider: platformv1.IdentityProviderGCP, Role: "web@shared.iam.gserviceaccount.com"},
+			want:     "web@shared.iam.gserviceaccount.com",
+		},
+		{
+			name:     "Azure client ID",
+			identity: platformv1.IdentitySpec{Provider: platformv1.IdentityProviderAzure, Role: "client-id"},
+			want:     "client-id",
+		},
+		{
+			name:     "Azure without client ID",
+			identity: platformv1.IdentitySpec{Provider: platformv1.IdentityProviderAzure},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := identityReconciler()
+			if tt.config != nil {
+				tt.config(r)
+			}
+			workload := testWorkload("app:v1")
+			workload.Spec.Identity = &tt.identity
+
+			got, err := r.identityPrincipal(*workload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("identityPrincipal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("identityPrincipal() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDesiredServiceAccountIdentity(t *testing.T) {
+	tests := []struct {
+		provider        platformv1.IdentityProvider
+		role            string
+		wantAnnotations map[string]string
+		wantPodLabels   map[string]string
+	}{
+		{
+			provider: platformv1.IdentityProviderAWS,
+			wantAnnotations: map[string]string{
+				awsRoleARNAnnotation:              "arn:aws:iam::123456789012:role/prod-default-web",
+				awsSTSRegionalEndpointsAnnotation: "true",
+			},
+			wantPodLabels: map[string]string{workloadLabel: "web"},
+		},
+		{
+			provider:        platformv1.IdentityProviderGCP,
+			wantAnnotations: map[string]string{gcpServiceAccountAnnotation: "default-web@platform.iam.gserviceaccount.com"},
+			wantPodLabels:   map[string]string{workloadLabel: "web"},
+		},
+		{
+			provider:        platformv1.IdentityProviderAzure,
+			role:            "client-id",
+			wantAnnotations: map[string]string{azureClientIDAnnotation: "client-id", azureTenantIDAnnotation: "tenant"},
+			wantPodLabels:   map[string]string{workloadLabel: "web", azureUseLabel: "true"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.provider), func(t *testing.T) {
+			r := identityReconciler()
+			workload := testWorkload("app:v1")
+			workload.Spec.Identity = &platformv1.IdentitySpec{Provider: tt.provider, Role: tt.role}
+
+			svcAccount, err := r.desiredServiceAccount(*workload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(svcAccount.Annotations, tt.wantAnnotations) {
+				t.Errorf("expected the annotations %v, got %v", tt.wantAnnotations, svcAccount.Annotations)
+			}
+			if got := podLabels(*workload); !reflect.DeepEqual(got, tt.wantPodLabels) {
+				t.Errorf("expected the pod labels %v, got %v", tt.wantPodLabels, got)
+			}
+
+			status, err := r.identityStatus(*workload, svcAccount.Namespace, svcAccount.Name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if status.Issuer != "https://oidc.example.com" || status.Subject != "system:serviceaccount:default:web" {
+				t.Errorf("expected the ServiceAccount web to be trusted, got %v", status)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// inventoryOf returns the inventory references of the given objects. Only the
+// identifying fields are recorded, so the inventory does not change with every
+// update of the objects.
+func (r *WorkloadReconciler) inventoryOf(objs []client.Object) ([]corev1.ObjectReference, error) {
+	refs := make([]corev1.ObjectReference, 0, len(objs))
+	for _, obj := range objs {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return nil, err
+		}
+
+		apiVersion, kind := gvk.ToAPIVersionAndKind()
+		refs = append(refs, corev1.ObjectReference{
+			APIVersion: apiVersion,
+			Kind:       kind,
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+		})
+	}
+
+	return refs, nil
+}
+
+// containsObject reports whether refs contains a reference to the same object
+// as ref. The API version is ignored, so objects are matched across versions.
+func containsObject(refs []corev1.ObjectReference, ref corev1.ObjectReference) bool {
+	group := schema.FromAPIVersionAndKind(ref.APIVersion, ref.Kind).Group
+	for _, other := range refs {
+		if other.Kind == ref.Kind && other.Namespace == ref.Namespace && other.Name == ref.Name &&
+			schema.FromAPIVersionAndKind(other.APIVersion, other.Kind).Group == group {
+			return true
+		}
+	}
+
+	return false
+}
+
+// pruneChildren deletes the objects of the workload inventory that are not part
+// of the desired inventory. Objects that are no longer controlled by the
+// workload are left alone.
+func (r *WorkloadReconciler) pruneChildren(ctx context.Context, workload *platformv1.Workload, desired []corev1.ObjectReference) error {
+	log := log.
+		FromContext(ctx)
+
+	for _, ref := range workload.Status.Inventory {
+		if containsObject(desired, ref) {
+			continue
+		}
+
+		obj := objectFromReference(ref)
+		if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			// the object or its kind is already gone
+			if client.IgnoreNotFound(err) == nil || meta.IsNoMatchError(err) {
+				continue
+			}
+			return err
+		}
+
+		if !metav1.IsControlledBy(obj, workload) {
+			log.Info("skipping prune of object not controlled by workload", "kind", ref.Kind, "name", ref.Name)
+			continue
+		}
+
+		log.Info("pruning object", "kind", ref.Kind, "name", ref.Name)
+		if err := r.deleteChild(ctx, ref); err != nil {
+			r.Recorder.Eventf(workload, corev1.EventTypeWarning, reasonPruneFailed, "Failed to prune %s %s: %s", ref.Kind, ref.Name, err)
+			return err
+		}
+		r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonPruned, "Pruned %s %s", ref.Kind, ref.Name)
+	}
+
+	return nil
+}
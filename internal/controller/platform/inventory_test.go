@@ -0,0 +1,110 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func TestContainsObject(t *testing.T) {
+	refs := []corev1.ObjectReference{
+		{APIVersion: "autoscaling/v2", Kind: "HorizontalPodAutoscaler", Namespace: "default", Name: "web"},
+		{APIVersion: "v1", Kind: "Service", Namespace: "default", Name: "web"},
+	}
+
+	tests := []struct {
+		name string
+		ref  corev1.ObjectReference
+		want bool
+	}{
+		{name: "same object", ref: refs[1], want: true},
+		{
+			name: "other version of the group",
+			ref:  corev1.ObjectReference{APIVersion: "autoscaling/v1", Kind: "HorizontalPodAutoscaler", Namespace: "default", Name: "web"},
+			want: true,
+		},
+		{
+			name: "other group",
+			ref:  corev1.ObjectReference{APIVersion: "serving.knative.dev/v1", Kind: "Service", Namespace: "default", Name: "web"},
+		},
+		{
+			name: "other name",
+			ref:  corev1.ObjectReference{APIVersion: "v1", Kind: "Service", Namespace: "default", Name: "web-canary"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := containsObject(refs, tt.ref); got != tt.want {
+				t.Errorf("containsObject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPruneChildren(t *testing.T) {
+	ctx := context.Background()
+	workload := testWorkload("app:v1")
+	r := newTestReconciler()
+
+	owned := func(obj client.Object) client.Object {
+		t.Helper()
+		if err := ctrl.SetControllerReference(workload, obj, r.Scheme); err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	deployment := owned(testDeployment("web", "app:v1", 4))
+	service := owned(&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}})
+	// a ConfigMap taken over by others
+	configMap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+	r = newTestReconciler(deployment, service, configMap)
+
+	inventory, err := r.inventoryOf([]client.Object{deployment, service, configMap,
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	workload.Status.Inventory = inventory
+
+	if err := r.pruneChildren(ctx, workload, inventory[1:2]); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, r, reasonPruned)
+	if events := r.Recorder.(*record.FakeRecorder).Events; len(events) != 0 {
+		t.Errorf("expected only the Deployment to be pruned, got %s", <-events)
+	}
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(deployment), &appsv1.Deployment{}); !apierrors.IsNotFound(err) {
+		t.Errorf("expected the Deployment to be pruned, got %v", err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(service), &corev1.Service{}); err != nil {
+		t.Errorf("expected the desired Service to be kept, got %v", err)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(configMap), &corev1.ConfigMap{}); err != nil {
+		t.Errorf("expected the ConfigMap not controlled by the workload to be kept, got %v", err)
+	}
+}
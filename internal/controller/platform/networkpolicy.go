@@ -0,0 +1,165 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// dnsPort is the port allowed for name resolution of restricted workloads
+const dnsPort = 53
+
+// desiredNetworkPolicy returns the NetworkPolicy of the workload, or nil when
+// neither the workload nor the operator restrict its traffic.
+func (r *WorkloadReconciler) desiredNetworkPolicy(workload platformv1.Workload) (*networkingv1.NetworkPolicy, error) {
+	var network platformv1.NetworkSpec
+	if workload.Spec.Network != nil {
+		network = *workload.Spec.Network
+	}
+
+	config := r.Config.NetworkPolicies
+	restrictIngress := config.DefaultDeny || len(network.AllowFrom) > 0
+	restrictEgress := config.DefaultDeny || len(network.AllowTo) > 0
+	if !restrictIngress && !restrictEgress {
+		return nil, nil
+	}
+
+	policy := &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{APIVersion: networkingv1.SchemeGroupVersion.String(), Kind: "NetworkPolicy"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workload.Name,
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{
+				MatchLabels: selectorLabels(workload),
+			},
+		},
+	}
+
+	if restrictIngress {
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeIngress)
+
+		for _, peer := range network.AllowFrom {
+			policy.Spec.Ingress = append(policy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+				From:  []networkingv1.NetworkPolicyPeer{networkPolicyPeer(workload, peer)},
+				Ports: networkPolicyPorts(peer.Ports),
+			})
+		}
+
+		if workload.Spec.Ingress != nil {
+			var from []networkingv1.NetworkPolicyPeer
+			for _, namespace := range r.ingressNamespaces() {
+				from = append(from, networkingv1.NetworkPolicyPeer{NamespaceSelector: namespaceSelector(namespace)})
+			}
+			if len(from) > 0 {
+				policy.Spec.Ingress = append(policy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{From: from})
+			}
+		}
+	}
+
+	if restrictEgress {
+		policy.Spec.PolicyTypes = append(policy.Spec.PolicyTypes, networkingv1.PolicyTypeEgress)
+
+		for _, peer := range network.AllowTo {
+			policy.Spec.Egress = append(policy.Spec.Egress, networkingv1.NetworkPolicyEgressRule{
+				To:    []networkingv1.NetworkPolicyPeer{networkPolicyPeer(workload, peer)},
+				Ports: networkPolicyPorts(peer.Ports),
+			})
+		}
+
+		if config.AllowDNS == nil || *config.AllowDNS {
+			policy.Spec.Egress = append(policy.Spec.Egress, networkingv1.NetworkPolicyEgressRule{
+				Ports: networkPolicyPorts([]platformv1.NetworkPort{
+					{Protocol: corev1.ProtocolUDP, Port: intstr.FromInt(dnsPort)},
+					{Protocol: corev1.ProtocolTCP, Port: intstr.FromInt(dnsPort)},
+				}),
+			})
+		}
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, policy, r.Scheme); err != nil {
+		return policy, err
+	}
+
+	return policy, nil
+}
+
+// ingressNamespaces returns the namespaces that route external traffic to workloads.
+func (r *WorkloadReconciler) ingressNamespaces() []string {
+	namespaces := append([]string{}, r.Config.NetworkPolicies.IngressNamespaces...)
+	if r.Config.Routing.Mode == configv1alpha1.RoutingModeGatewayAPI && r.Config.Routing.Gateway != nil {
+		namespaces = append(namespaces, r.Config.Routing.Gateway.Namespace)
+	}
+
+	return namespaces
+}
+
+// networkPolicyPeer returns the NetworkPolicy peer selecting the pods of a
+// workload or namespace.
+func networkPolicyPeer(workload platformv1.Workload, peer platformv1.NetworkPeer) networkingv1.NetworkPolicyPeer {
+	if peer.Workload == "" {
+		return networkingv1.NetworkPolicyPeer{NamespaceSelector: namespaceSelector(peer.Namespace)}
+	}
+
+	policyPeer := networkingv1.NetworkPolicyPeer{
+		PodSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{workloadLabel: peer.Workload},
+		},
+	}
+	// without a namespace selector, pods are selected in the namespace of the policy
+	if peer.Namespace != "" && peer.Namespace != workload.Namespace {
+		policyPeer.NamespaceSelector = namespaceSelector(peer.Namespace)
+	}
+
+	return policyPeer
+}
+
+// namespaceSelector returns the selector of a namespace by its name.
+func namespaceSelector(namespace string) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: map[string]string{corev1.LabelMetadataName: namespace},
+	}
+}
+
+// networkPolicyPorts returns the NetworkPolicy ports of the allowed traffic.
+func networkPolicyPorts(ports []platformv1.NetworkPort) []networkingv1.NetworkPolicyPort {
+	var policyPorts []networkingv1.NetworkPolicyPort
+	for _, port := range ports {
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		number := port.Port
+
+		policyPorts = append(policyPorts, networkingv1.NetworkPolicyPort{
+			Protocol: &protocol,
+			Port:     &number,
+		})
+	}
+
+	return policyPorts
+}
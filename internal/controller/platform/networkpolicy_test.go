@@ -0,0 +1,132 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+func TestDesiredNetworkPolicy(t *testing.T) {
+	tcp, udp := corev1.ProtocolTCP, corev1.ProtocolUDP
+	port8080, port53 := intstr.FromInt(8080), intstr.FromInt(dnsPort)
+	dns := networkingv1.NetworkPolicyEgressRule{Ports: []networkingv1.NetworkPolicyPort{
+		{Protocol: &udp, Port: &port53},
+		{Protocol: &tcp, Port: &port53},
+	}}
+	podsOf := func(workload string) *metav1.LabelSelector {
+		return &metav1.LabelSelector{MatchLabels: map[string]string{workloadLabel: workload}}
+	}
+
+	tests := []struct {
+		name        string
+		config      configv1alpha1.NetworkPolicies
+		network     *platformv1.NetworkSpec
+		ingress     bool
+		wantNil     bool
+		wantTypes   []networkingv1.PolicyType
+		wantIngress []networkingv1.NetworkPolicyIngressRule
+		wantEgress  []networkingv1.NetworkPolicyEgressRule
+	}{
+		{name: "unrestricted", wantNil: true},
+		{
+			name:       "default deny",
+			config:     configv1alpha1.NetworkPolicies{DefaultDeny: true},
+			wantTypes:  []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			wantEgress: []networkingv1.NetworkPolicyEgressRule{dns},
+		},
+		{
+			name: "allowed ingress",
+			config: configv1alpha1.NetworkPolicies{
+				IngressNamespaces: []string{"ingress-nginx"},
+			},
+			network: &platformv1.NetworkSpec{AllowFrom: []platformv1.NetworkPeer{
+				{Workload: "frontend", Ports: []platformv1.NetworkPort{{Port: port8080}}},
+				{Namespace: "monitoring"},
+			}},
+			ingress:   true,
+			wantTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+			wantIngress: []networkingv1.NetworkPolicyIngressRule{
+				{
+					From:  []networkingv1.NetworkPolicyPeer{{PodSelector: podsOf("frontend")}},
+					Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port8080}},
+				},
+				{From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: namespaceSelector("monitoring")}}},
+				{From: []networkingv1.NetworkPolicyPeer{{NamespaceSelector: namespaceSelector("ingress-nginx")}}},
+			},
+		},
+		{
+			name:   "allowed egress without DNS",
+			config: configv1alpha1.NetworkPolicies{AllowDNS: pointer.Bool(false)},
+			network: &platformv1.NetworkSpec{AllowTo: []platformv1.NetworkPeer{
+				{Workload: "db", Namespace: "data"},
+				{Workload: "cache", Namespace: "default"},
+			}},
+			wantTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+			wantEgress: []networkingv1.NetworkPolicyEgressRule{
+				{To: []networkingv1.NetworkPolicyPeer{{PodSelector: podsOf("db"), NamespaceSelector: namespaceSelector("data")}}},
+				{To: []networkingv1.NetworkPolicyPeer{{PodSelector: podsOf("cache")}}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler()
+			r.Config.NetworkPolicies = tt.config
+			workload := testWorkload("app:v1")
+			workload.Spec.Network = tt.network
+			if tt.ingress {
+				workload.Spec.Ingress = &platformv1.IngressSpec{Hosts: []string{"example.com"}}
+			}
+
+			policy, err := r.desiredNetworkPolicy(*workload)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.wantNil {
+				if policy != nil {
+					t.Errorf("expected no NetworkPolicy, got %v", policy.Spec)
+				}
+				return
+			}
+
+			expectOwned(t, policy)
+			if !reflect.DeepEqual(policy.Spec.PodSelector, *podsOf("web")) {
+				t.Errorf("expected the pods of web to be selected, got %v", policy.Spec.PodSelector)
+			}
+			if !reflect.DeepEqual(policy.Spec.PolicyTypes, tt.wantTypes) {
+				t.Errorf("expected the policy types %v, got %v", tt.wantTypes, policy.Spec.PolicyTypes)
+			}
+			if !reflect.DeepEqual(policy.Spec.Ingress, tt.wantIngress) {
+				t.Errorf("expected the ingress rules %v, got %v", tt.wantIngress, policy.Spec.Ingress)
+			}
+			if !reflect.DeepEqual(policy.Spec.Egress, tt.wantEgress) {
+				t.Errorf("expected the egress rules %v, got %v", tt.wantEgress, policy.Spec.Egress)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+const (
+	// reconcileAnnotation set to reconcileDisabled pauses a workload in emergencies
+	reconcileAnnotation = "platform.mydev.org/reconcile"
+	reconcileDisabled   = "disabled"
+)
+
+// pausedReason returns why the reconciliation of the workload is paused, or an
+// empty string when it is not.
+func pausedReason(workload platformv1.Workload) string {
+	switch {
+	case workload.Annotations[reconcileAnnotation] == reconcileDisabled:
+		return reasonReconcileDisabled
+	case workload.Spec.Paused:
+		return reasonPaused
+	default:
+		return ""
+	}
+}
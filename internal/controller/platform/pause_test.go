@@ -0,0 +1,108 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+func TestReconcileResumeCorrectsDrift(t *testing.T) {
+	ctx := context.Background()
+	workload := testWorkload("app:v1")
+	workload.Spec.DriftPolicy = platformv1.DriftPolicyReport
+	r := newTestReconciler(workload)
+	key := client.ObjectKeyFromObject(workload)
+
+	reconcile := func(change func(*platformv1.Workload)) {
+		t.Helper()
+		if change != nil {
+			if err := r.Get(ctx, key, workload); err != nil {
+				t.Fatal(err)
+			}
+			change(workload)
+			if err := r.Update(ctx, workload); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Get(ctx, key, workload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// editImage changes the image of the Deployment by hand
+	editImage := func(image string) {
+		t.Helper()
+		var deployment appsv1.Deployment
+		if err := r.Get(ctx, key, &deployment); err != nil {
+			t.Fatal(err)
+		}
+		deployment.Spec.Template.Spec.Containers[0].Image = image
+		deployment.ManagedFields = []metav1.ManagedFieldsEntry{
+			managedFieldsEntry("kubectl-edit", "", `{"f:spec":{"f:template":{"f:spec":{"f:containers":{`+
+				`"k:{\"name\":\"`+workloadContainerName+`\"}":{"f:image":{}}}}}}}`),
+		}
+		if err := r.Update(ctx, &deployment); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectImage := func(want string) {
+		t.Helper()
+		var deployment appsv1.Deployment
+		if err := r.Get(ctx, key, &deployment); err != nil {
+			t.Fatal(err)
+		}
+		if got := containerImage(deployment.Spec.Template); got != want {
+			t.Errorf("expected the Deployment to run %s, got %s", want, got)
+		}
+	}
+
+	reconcile(nil)
+	expectImage("app:v1")
+
+	reconcile(func(w *platformv1.Workload) { w.Spec.Paused = true })
+	if !meta.IsStatusConditionTrue(workload.Status.Conditions, typePausedWorkload) {
+		t.Fatal("expected the workload to be paused")
+	}
+	editImage("app:debug")
+	reconcile(nil)
+	expectImage("app:debug")
+
+	// resuming corrects the changes made while paused, once
+	reconcile(func(w *platformv1.Workload) { w.Spec.Paused = false })
+	expectImage("app:v1")
+	expectEvent(t, r, reasonResumed)
+	expectEvent(t, r, reasonDriftCorrected)
+	if meta.FindStatusCondition(workload.Status.Conditions, typePausedWorkload) != nil {
+		t.Error("expected the Paused condition to be removed")
+	}
+
+	editImage("app:debug")
+	reconcile(nil)
+	expectImage("app:debug")
+	expectEvent(t, r, reasonDriftDetected)
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// validatePermissions checks the permissions of the workload against the
+// allowlist of the operator, so that workloads can not escalate beyond it.
+func (r *WorkloadReconciler) validatePermissions(workload platformv1.Workload) error {
+	permissions := workload.Spec.Permissions
+	if permissions == nil {
+		return nil
+	}
+
+	allowed := r.Config.Permissions
+	for _, name := range permissions.ClusterRoles {
+		if !containsString(allowed.ApprovedClusterRoles, name) {
+			return fmt.Errorf("ClusterRole %s is not approved", name)
+		}
+	}
+
+	for i, rule := range permissions.Rules {
+		if len(rule.NonResourceURLs) > 0 {
+			return fmt.Errorf("rule %d: non-resource URLs can not be granted in a namespace", i)
+		}
+		if !ruleAllowed(allowed.AllowedRules, rule) {
+			return fmt.Errorf("rule %d: %s is not covered by the allowed rules", i, rule.String())
+		}
+	}
+
+	return nil
+}
+
+// ruleAllowed reports whether every permission of the rule is covered by a
+// single allowed rule.
+func ruleAllowed(allowedRules []rbacv1.PolicyRule, rule rbacv1.PolicyRule) bool {
+	for _, allowed := range allowedRules {
+		if covers(allowed.APIGroups, rule.APIGroups) &&
+			covers(allowed.Resources, rule.Resources) &&
+			covers(allowed.Verbs, rule.Verbs) &&
+			(len(allowed.ResourceNames) == 0 || (len(rule.ResourceNames) > 0 && covers(allowed.ResourceNames, rule.ResourceNames))) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// covers reports whether all values are in the allowed values. A wildcard value
+// is only covered by an allowed wildcard.
+func covers(allowed, values []string) bool {
+	if containsString(allowed, rbacv1.ResourceAll) {
+		return true
+	}
+
+	for _, value := range values {
+		if !containsString(allowed, value) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// desiredRole returns the Role with the rules of the workload, or nil when the
+// workload has no rules.
+func (r *WorkloadReconciler) desiredRole(workload platformv1.Workload) (*rbacv1.Role, error) {
+	if workload.Spec.Permissions == nil || len(workload.Spec.Permissions.Rules) == 0 {
+		return nil, nil
+	}
+
+	role := &rbacv1.Role{
+		TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workload.Name,
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Rules: workload.Spec.Permissions.Rules,
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, role, r.Scheme); err != nil {
+		return role, err
+	}
+
+	return role, nil
+}
+
+// desiredRoleBindings returns the RoleBindings of the Role and the ClusterRoles
+// of the workload to its ServiceAccount.
+func (r *WorkloadReconciler) desiredRoleBindings(workload platformv1.Workload, svcAccount corev1.ServiceAccount, role *rbacv1.Role) ([]rbacv1.RoleBinding, error) {
+	var roleRefs []rbacv1.RoleRef
+	if role != nil {
+		roleRefs = append(roleRefs, rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role.Name})
+	}
+	if workload.Spec.Permissions != nil {
+		for _, name := range workload.Spec.Permissions.ClusterRoles {
+			roleRefs = append(roleRefs, rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name})
+		}
+	}
+
+	bindings := make([]rbacv1.RoleBinding, 0, len(roleRefs))
+	for _, roleRef := range roleRefs {
+		// the webhook rejects workload names containing "-clusterrole-", so the
+		// bindings of a workload never clash with those of another one
+		name := workload.Name
+		if roleRef.Kind == "ClusterRole" {
+			name = fmt.Sprintf("%s-clusterrole-%s", workload.Name, roleRef.Name)
+		}
+
+		binding := rbacv1.RoleBinding{
+			TypeMeta: metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "RoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: workload.Namespace,
+				Labels:    selectorLabels(workload),
+			},
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.ServiceAccountKind, Name: svcAccount.Name, Namespace: svcAccount.Namespace},
+			},
+			RoleRef: roleRef,
+		}
+
+		// always set the controller reference so that we know which object owns this.
+		if err := ctrl.SetControllerReference(&workload, &binding, r.Scheme); err != nil {
+			return bindings, err
+		}
+
+		bindings = append(bindings, binding)
+	}
+
+	return bindings, nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+func TestRuleAllowed(t *testing.T) {
+	allowedRules := []rbacv1.PolicyRule{
+		{APIGroups: []string{""}, Resources: []string{"configmaps", "pods"}, Verbs: []string{"get", "list", "watch"}},
+		{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}, ResourceNames: []string{"app-tls"}},
+		{APIGroups: []string{"batch"}, Resources: []string{"*"}, Verbs: []string{"get"}},
+	}
+
+	tests := []struct {
+		name string
+		rule rbacv1.PolicyRule
+		want bool
+	}{
+		{
+			name: "subset of an allowed rule",
+			rule: rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get", "list"}},
+			want: true,
+		},
+		{
+			name: "verb not allowed",
+			rule: rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"delete"}},
+		},
+		{
+			name: "wildcard verb is only covered by a wildcard",
+			rule: rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"*"}},
+		},
+		{
+			name: "wildcard resource of an allowed rule",
+			rule: rbacv1.PolicyRule{APIGroups: []string{"batch"}, Resources: []string{"jobs", "cronjobs"}, Verbs: []string{"get"}},
+			want: true,
+		},
+		{
+			name: "resources of two allowed rules",
+			rule: rbacv1.PolicyRule{APIGroups: []string{"", "batch"}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+		},
+		{
+			name: "allowed resource name",
+			rule: rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}, ResourceNames: []string{"app-tls"}},
+			want: true,
+		},
+		{
+			name: "other resource name",
+			rule: rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}, ResourceNames: []string{"other"}},
+		},
+		{
+			name: "all names of a resource restricted by name",
+			rule: rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ruleAllowed(allowedRules, tt.rule); got != tt.want {
+				t.Errorf("ruleAllowed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePermissions(t *testing.T) {
+	r := &WorkloadReconciler{Config: configv1alpha1.OperatorConfig{
+		Permissions: configv1alpha1.Permissions{
+			AllowedRules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}},
+			},
+			ApprovedClusterRoles: []string{"view"},
+		},
+	}}
+
+	tests := []struct {
+		name        string
+		permissions *platformv1.PermissionsSpec
+		wantErr     bool
+	}{
+		{
+			name: "no permissions",
+		},
+		{
+			name: "allowed rules and approved ClusterRoles",
+			permissions: &platformv1.PermissionsSpec{
+				Rules:        []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}},
+				ClusterRoles: []string{"view"},
+			},
+		},
+		{
+			name:        "ClusterRole not approved",
+			permissions: &platformv1.PermissionsSpec{ClusterRoles: []string{"admin"}},
+			wantErr:     true,
+		},
+		{
+			name: "rule not allowed",
+			permissions: &platformv1.PermissionsSpec{
+				Rules: []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get"}}},
+			},
+			wantErr: true,
+		},
+		{
+			name: "non-resource URLs",
+			permissions: &platformv1.PermissionsSpec{
+				Rules: []rbacv1.PolicyRule{{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}}},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := platformv1.Workload{Spec: platformv1.WorkloadSpec{Permissions: tt.permissions}}
+			if err := r.validatePermissions(workload); (err != nil) != tt.wantErr {
+				t.Errorf("validatePermissions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDesiredRoleBindings(t *testing.T) {
+	r := newTestReconciler()
+
+	bindingNames := func(workload *platformv1.Workload) []string {
+		t.Helper()
+		svcAccount, err := r.desiredServiceAccount(*workload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		role, err := r.desiredRole(*workload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		bindings, err := r.desiredRoleBindings(*workload, svcAccount, role)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, binding := range bindings {
+			if len(binding.Subjects) != 1 || binding.Subjects[0].Name != svcAccount.Name {
+				t.Errorf("expected %s to bind the ServiceAccount %s, got %v", binding.Name, svcAccount.Name, binding.Subjects)
+			}
+			names = append(names, binding.Name)
+		}
+		return names
+	}
+
+	// the ClusterRole binding of "api" does not clash with the Role binding of "api-view"
+	api := testWorkload("app:v1")
+	api.Name = "api"
+	api.Spec.Permissions = &platformv1.PermissionsSpec{
+		Rules:        []rbacv1.PolicyRule{{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get"}}},
+		ClusterRoles: []string{"view"},
+	}
+	apiView := api.DeepCopy()
+	apiView.Name = "api-view"
+	apiView.Spec.Permissions.ClusterRoles = nil
+
+	if got, want := bindingNames(api), []string{"api", "api-clusterrole-view"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the RoleBindings %v, got %v", want, got)
+	}
+	if got, want := bindingNames(apiView), []string{"api-view"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the RoleBindings %v, got %v", want, got)
+	}
+}
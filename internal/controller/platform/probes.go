@@ -0,0 +1,127 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// probePort returns the first TCP port of the container of the workload, the
+// port of its default probes.
+func probePort(workload platformv1.Workload) (corev1.ContainerPort, bool) {
+	for _, port := range workload.Spec.Container.Ports {
+		if port.Protocol == "" || port.Protocol == corev1.ProtocolTCP {
+			return port, true
+		}
+	}
+
+	return corev1.ContainerPort{}, false
+}
+
+// usesDefaultProbes reports whether the container of the workload gets the
+// probes configured in the operator.
+func usesDefaultProbes(workload platformv1.Workload) bool {
+	defaults := workload.Spec.Container.DefaultProbes
+	_, found := probePort(workload)
+	return !isBatch(workload) && found && (defaults == nil || *defaults)
+}
+
+// defaultProbe returns the probe of the first TCP port of the container of the
+// workload, or nil when there is no default.
+func defaultProbe(workload platformv1.Workload, probe *configv1alpha1.DefaultProbe) *corev1.Probe {
+	if probe == nil || !usesDefaultProbes(workload) {
+		return nil
+	}
+
+	port, _ := probePort(workload)
+	target := intstr.FromInt(int(port.ContainerPort))
+	if port.Name != "" {
+		target = intstr.FromString(port.Name)
+	}
+
+	handler := corev1.ProbeHandler{
+		TCPSocket: &corev1.TCPSocketAction{Port: target},
+	}
+	if probe.Type == configv1alpha1.ProbeTypeHTTP ||
+		(probe.Type != configv1alpha1.ProbeTypeTCP && strings.HasPrefix(port.Name, "http")) {
+		handler = corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{Path: probe.Path, Port: target},
+		}
+	}
+
+	return &corev1.Probe{
+		ProbeHandler:        handler,
+		InitialDelaySeconds: probe.InitialDelaySeconds,
+		PeriodSeconds:       probe.PeriodSeconds,
+		TimeoutSeconds:      probe.TimeoutSeconds,
+		FailureThreshold:    probe.FailureThreshold,
+	}
+}
+
+// applyProbeDefaults sets the probes and the lifecycle of the container of the
+// workload, falling back to the defaults configured in the operator.
+func (r *WorkloadReconciler) applyProbeDefaults(workload platformv1.Workload, container *corev1.Container) {
+	spec := *workload.Spec.Container
+	defaults := r.Config.Probes
+
+	container.LivenessProbe = spec.LivenessProbe
+	if container.LivenessProbe == nil {
+		container.LivenessProbe = defaultProbe(workload, defaults.Liveness)
+	}
+
+	container.ReadinessProbe = spec.ReadinessProbe
+	if container.ReadinessProbe == nil {
+		container.ReadinessProbe = defaultProbe(workload, defaults.Readiness)
+	}
+
+	container.StartupProbe = spec.StartupProbe
+	if container.StartupProbe == nil {
+		container.StartupProbe = defaultProbe(workload, defaults.Startup)
+	}
+
+	container.Lifecycle = spec.Lifecycle
+	if (container.Lifecycle == nil || container.Lifecycle.PreStop == nil) &&
+		defaults.PreStopSleepSeconds != nil && !isBatch(workload) {
+		lifecycle := &corev1.Lifecycle{}
+		if container.Lifecycle != nil {
+			lifecycle = container.Lifecycle.DeepCopy()
+		}
+		lifecycle.PreStop = &corev1.LifecycleHandler{
+			Exec: &corev1.ExecAction{
+				Command: []string{"sleep", fmt.Sprint(*defaults.PreStopSleepSeconds)},
+			},
+		}
+		container.Lifecycle = lifecycle
+	}
+}
+
+// terminationGracePeriodSeconds returns the termination grace period of the
+// pods of the workload.
+func (r *WorkloadReconciler) terminationGracePeriodSeconds(workload platformv1.Workload) *int64 {
+	if workload.Spec.TerminationGracePeriodSeconds != nil {
+		return workload.Spec.TerminationGracePeriodSeconds
+	}
+
+	return r.Config.Probes.TerminationGracePeriodSeconds
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/pointer"
+
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+func TestDefaultProbe(t *testing.T) {
+	probe := &configv1alpha1.DefaultProbe{Path: "/healthz", PeriodSeconds: 10}
+	http := corev1.ContainerPort{Name: "http", ContainerPort: 8080}
+	dns := corev1.ContainerPort{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP}
+	metrics := corev1.ContainerPort{ContainerPort: 9090, Protocol: corev1.ProtocolTCP}
+
+	tests := []struct {
+		name    string
+		ports   []corev1.ContainerPort
+		probe   *configv1alpha1.DefaultProbe
+		change  func(*platformv1.Workload)
+		want    *corev1.ProbeHandler
+		wantNil bool
+	}{
+		{
+			name:  "http port",
+			ports: []corev1.ContainerPort{http},
+			probe: probe,
+			want:  &corev1.ProbeHandler{HTTPGet: &corev1.HTTPGetAction{Path: "/healthz", Port: intstr.FromString("http")}},
+		},
+		{
+			name:  "tcp probe of an http port",
+			ports: []corev1.ContainerPort{http},
+			probe: &configv1alpha1.DefaultProbe{Type: configv1alpha1.ProbeTypeTCP},
+			want:  &corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromString("http")}},
+		},
+		{
+			name:  "first TCP port after a UDP port",
+			ports: []corev1.ContainerPort{dns, metrics},
+			probe: probe,
+			want:  &corev1.ProbeHandler{TCPSocket: &corev1.TCPSocketAction{Port: intstr.FromInt(9090)}},
+		},
+		{name: "no TCP port", ports: []corev1.ContainerPort{dns}, probe: probe, wantNil: true},
+		{name: "no ports", probe: probe, wantNil: true},
+		{name: "no default", ports: []corev1.ContainerPort{http}, wantNil: true},
+		{
+			name:    "default probes disabled",
+			ports:   []corev1.ContainerPort{http},
+			probe:   probe,
+			change:  func(w *platformv1.Workload) { w.Spec.Container.DefaultProbes = pointer.Bool(false) },
+			wantNil: true,
+		},
+		{
+			name:    "job",
+			ports:   []corev1.ContainerPort{http},
+			probe:   probe,
+			change:  func(w *platformv1.Workload) { w.Spec.Kind = platformv1.WorkloadKindJob },
+			wantNil: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workload := testWorkload("app:v1")
+			workload.Spec.Container.Ports = tt.ports
+			if tt.change != nil {
+				tt.change(workload)
+			}
+
+			got := defaultProbe(*workload, tt.probe)
+			if tt.wantNil {
+				if got != nil {
+					t.Errorf("expected no probe, got %v", got)
+				}
+				return
+			}
+			if got == nil || !equality.Semantic.DeepEqual(got.ProbeHandler, *tt.want) {
+				t.Errorf("defaultProbe() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// prometheusTimeout bounds the duration of a Prometheus query
+const prometheusTimeout = 10 * time.Second
+
+// errNoData is returned for a query without a result, e.g. without traffic
+var errNoData = errors.New("Prometheus query returned no data")
+
+// prometheusResponse is the response of the Prometheus instant query API.
+type prometheusResponse struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+	Data   struct {
+		ResultType string          `json:"resultType"`
+		Result     json.RawMessage `json:"result"`
+	} `json:"data"`
+}
+
+// queryPrometheus evaluates an instant query returning a single value against
+// the Prometheus-compatible API of the operator configuration. An empty result
+// or a ratio without traffic returns errNoData.
+func (r *WorkloadReconciler) queryPrometheus(ctx context.Context, query string) (float64, error) {
+	address := r.Config.Rollouts.PrometheusURL
+	if address == "" {
+		return 0, fmt.Errorf("no Prometheus URL is configured for the analysis")
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(address, "/") + "/api/v1/query")
+	if err != nil {
+		return 0, fmt.Errorf("invalid Prometheus URL: %w", err)
+	}
+	endpoint.RawQuery = url.Values{"query": {query}}.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, prometheusTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	var result prometheusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("invalid Prometheus response with status %s: %w", resp.Status, err)
+	}
+	if result.Status != "success" {
+		return 0, fmt.Errorf("Prometheus query failed: %s", result.Error)
+	}
+
+	var sample [2]interface{}
+	switch result.Data.ResultType {
+	case "scalar":
+		if err := json.Unmarshal(result.Data.Result, &sample); err != nil {
+			return 0, err
+		}
+	case "vector":
+		var vector []struct {
+			Value [2]interface{} `json:"value"`
+		}
+		if err := json.Unmarshal(result.Data.Result, &vector); err != nil {
+			return 0, err
+		}
+		switch len(vector) {
+		case 0:
+			return 0, errNoData
+		case 1:
+			sample = vector[0].Value
+		default:
+			return 0, fmt.Errorf("Prometheus query returned %d series instead of one", len(vector))
+		}
+	default:
+		return 0, fmt.Errorf("unsupported Prometheus result type %q", result.Data.ResultType)
+	}
+
+	text, ok := sample[1].(string)
+	if !ok {
+		return 0, fmt.Errorf("invalid Prometheus sample %v", sample)
+	}
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	// a ratio without traffic is not a number
+	if math.IsNaN(value) {
+		return 0, errNoData
+	}
+
+	return value, nil
+}
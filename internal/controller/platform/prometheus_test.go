@@ -0,0 +1,138 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
+)
+
+// newPrometheus serves the given instant query response and counts the queries.
+func newPrometheus(t *testing.T, response string, queries *int) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v1/query" || req.URL.Query().Get("query") == "" {
+			http.NotFound(w, req)
+			return
+		}
+		if queries != nil {
+			*queries++
+		}
+		fmt.Fprint(w, response)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func vectorResponse(values ...string) string {
+	result := ""
+	for i, value := range values {
+		if i > 0 {
+			result += ","
+		}
+		result += fmt.Sprintf(`{"metric":{},"value":[1700000000,%q]}`, value)
+	}
+
+	return fmt.Sprintf(`{"status":"success","data":{"resultType":"vector","result":[%s]}}`, result)
+}
+
+func TestQueryPrometheus(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		want     float64
+		wantErr  error
+	}{
+		{
+			name:     "vector",
+			response: vectorResponse("0.25"),
+			want:     0.25,
+		},
+		{
+			name:     "scalar",
+			response: `{"status":"success","data":{"resultType":"scalar","result":[1700000000,"0.5"]}}`,
+			want:     0.5,
+		},
+		{
+			name:     "empty vector",
+			response: vectorResponse(),
+			wantErr:  errNoData,
+		},
+		{
+			name:     "ratio without traffic",
+			response: vectorResponse("NaN"),
+			wantErr:  errNoData,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newPrometheus(t, tt.response, nil)
+			r := &WorkloadReconciler{Config: configv1alpha1.OperatorConfig{
+				Rollouts: configv1alpha1.Rollouts{PrometheusURL: server.URL},
+			}}
+
+			got, err := r.queryPrometheus(context.Background(), "errors")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("queryPrometheus() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("queryPrometheus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQueryPrometheusErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+	}{
+		{
+			name:     "several series",
+			response: vectorResponse("0.1", "0.2"),
+		},
+		{
+			name:     "failed query",
+			response: `{"status":"error","error":"parse error"}`,
+		},
+		{
+			name:     "matrix",
+			response: `{"status":"success","data":{"resultType":"matrix","result":[]}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newPrometheus(t, tt.response, nil)
+			r := &WorkloadReconciler{Config: configv1alpha1.OperatorConfig{
+				Rollouts: configv1alpha1.Rollouts{PrometheusURL: server.URL},
+			}}
+
+			_, err := r.queryPrometheus(context.Background(), "errors")
+			if err == nil || errors.Is(err, errNoData) {
+				t.Errorf("queryPrometheus() error = %v, want a failed query", err)
+			}
+		})
+	}
+}
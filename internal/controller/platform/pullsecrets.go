@@ -0,0 +1,143 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// inheritsDefaultPullSecrets reports whether the workload uses the image pull
+// secrets configured in the operator.
+func inheritsDefaultPullSecrets(workload platformv1.Workload) bool {
+	spec := workload.Spec.ImagePullSecrets
+	return spec == nil || spec.InheritDefaults == nil || *spec.InheritDefaults
+}
+
+// copiedPullSecretName returns the name of the copy of an operator image pull
+// secret in the namespace of the workload.
+func copiedPullSecretName(workload platformv1.Workload, name string) string {
+	return fmt.Sprintf("%s-%s", workload.Name, name)
+}
+
+// imagePullSecrets returns the image pull secrets of the workload ServiceAccount.
+func (r *WorkloadReconciler) imagePullSecrets(workload platformv1.Workload) []corev1.LocalObjectReference {
+	var secrets []corev1.LocalObjectReference
+
+	if inheritsDefaultPullSecrets(workload) {
+		for _, name := range r.Config.ImagePullSecrets.Names {
+			if r.Config.ImagePullSecrets.Copy {
+				name = copiedPullSecretName(workload, name)
+			}
+			secrets = append(secrets, corev1.LocalObjectReference{Name: name})
+		}
+	}
+
+	if workload.Spec.ImagePullSecrets != nil {
+		secrets = append(secrets, workload.Spec.ImagePullSecrets.Secrets...)
+	}
+
+	return secrets
+}
+
+// desiredImagePullSecrets returns the copies of the operator image pull
+// secrets in the namespace of the workload, and the names of the operator
+// image pull secrets that do not exist.
+func (r *WorkloadReconciler) desiredImagePullSecrets(ctx context.Context, workload platformv1.Workload) ([]corev1.Secret, []string, error) {
+	if !r.Config.ImagePullSecrets.Copy || !inheritsDefaultPullSecrets(workload) {
+		return nil, nil, nil
+	}
+
+	var secrets []corev1.Secret
+	var missing []string
+	for _, name := range r.Config.ImagePullSecrets.Names {
+		var source corev1.Secret
+		key := types.NamespacedName{Namespace: *r.Config.Namespace, Name: name}
+		if err := r.Get(ctx, key, &source); err != nil {
+			if apierrors.IsNotFound(err) {
+				missing = append(missing, name)
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to get image pull secret %s: %w", key, err)
+		}
+
+		secret := corev1.Secret{
+			TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      copiedPullSecretName(workload, name),
+				Namespace: workload.Namespace,
+				Labels:    selectorLabels(workload),
+			},
+			Type: source.Type,
+			Data: source.Data,
+		}
+
+		// always set the controller reference so that we know which object owns this.
+		if err := ctrl.SetControllerReference(&workload, &secret, r.Scheme); err != nil {
+			return nil, nil, err
+		}
+
+		secrets = append(secrets, secret)
+	}
+
+	return secrets, missing, nil
+}
+
+// workloadsForPullSecret maps a change of an operator image pull secret to all
+// workloads, so that the copies are kept in sync.
+func (r *WorkloadReconciler) workloadsForPullSecret(ctx context.Context, obj client.Object) []reconcile.Request {
+	if !r.Config.ImagePullSecrets.Copy || obj.GetNamespace() != *r.Config.Namespace {
+		return nil
+	}
+
+	found := false
+	for _, name := range r.Config.ImagePullSecrets.Names {
+		if obj.GetName() == name {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil
+	}
+
+	var workloads platformv1.WorkloadList
+	if err := r.List(ctx, &workloads); err != nil {
+		log.FromContext(ctx).Error(err, "unable to list workloads for image pull secret", "secret", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(workloads.Items))
+	for _, workload := range workloads.Items {
+		requests = append(requests, reconcile.Request{
+			NamespacedName: types.NamespacedName{Namespace: workload.Namespace, Name: workload.Name},
+		})
+	}
+
+	return requests
+}
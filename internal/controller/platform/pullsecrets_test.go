@@ -0,0 +1,166 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/pointer"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// registrySecret returns the operator image pull secret "registry".
+func registrySecret(namespace string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "registry", Namespace: namespace},
+		Type:       corev1.SecretTypeDockerConfigJson,
+		Data:       map[string][]byte{corev1.DockerConfigJsonKey: []byte(`{"auths":{}}`)},
+	}
+}
+
+func TestImagePullSecrets(t *testing.T) {
+	own := []corev1.LocalObjectReference{{Name: "team-registry"}}
+
+	tests := []struct {
+		name string
+		copy bool
+		spec *platformv1.ImagePullSecretsSpec
+		want []string
+	}{
+		{name: "operator secrets", want: []string{"registry"}},
+		{name: "copied operator secrets", copy: true, want: []string{"web-registry"}},
+		{
+			name: "own and operator secrets",
+			spec: &platformv1.ImagePullSecretsSpec{Secrets: own},
+			want: []string{"registry", "team-registry"},
+		},
+		{
+			name: "without operator secrets",
+			spec: &platformv1.ImagePullSecretsSpec{Secrets: own, InheritDefaults: pointer.Bool(false)},
+			want: []string{"team-registry"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newTestReconciler()
+			r.Config.ImagePullSecrets.Names = []string{"registry"}
+			r.Config.ImagePullSecrets.Copy = tt.copy
+			workload := testWorkload("app:v1")
+			workload.Spec.ImagePullSecrets = tt.spec
+
+			var got []string
+			for _, secret := range r.imagePullSecrets(*workload) {
+				got = append(got, secret.Name)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("imagePullSecrets() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDesiredImagePullSecrets(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler()
+	source := registrySecret(*r.Config.Namespace)
+	if err := r.Create(ctx, source); err != nil {
+		t.Fatal(err)
+	}
+	r.Config.ImagePullSecrets.Names = []string{"registry", "mirror"}
+	workload := testWorkload("app:v1")
+
+	secrets, missing, err := r.desiredImagePullSecrets(ctx, *workload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 0 || len(missing) != 0 {
+		t.Errorf("expected the operator secrets not to be copied, got %d copies", len(secrets))
+	}
+
+	r.Config.ImagePullSecrets.Copy = true
+	secrets, missing, err = r.desiredImagePullSecrets(ctx, *workload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 1 {
+		t.Fatalf("expected a single copy, got %d", len(secrets))
+	}
+	secret := secrets[0]
+	if secret.Name != "web-registry" || secret.Namespace != "default" || secret.Type != source.Type ||
+		!reflect.DeepEqual(secret.Data, source.Data) {
+		t.Errorf("expected a copy of the registry secret in default, got %s/%s", secret.Namespace, secret.Name)
+	}
+	expectOwned(t, &secret)
+	if !reflect.DeepEqual(missing, []string{"mirror"}) {
+		t.Errorf("expected the mirror secret to be missing, got %v", missing)
+	}
+}
+
+func TestReconcileMissingPullSecret(t *testing.T) {
+	ctx := context.Background()
+	workload := testWorkload("app:v1")
+	r := newTestReconciler(workload)
+	r.Config.ImagePullSecrets.Names = []string{"registry"}
+	r.Config.ImagePullSecrets.Copy = true
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(workload)}); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, r, reasonPullSecretMissing)
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(workload), workload); err != nil {
+		t.Fatal(err)
+	}
+	degraded := meta.FindStatusCondition(workload.Status.Conditions, typeDegradedWorkload)
+	if degraded == nil || degraded.Status != metav1.ConditionTrue || degraded.Reason != reasonPullSecretMissing ||
+		!strings.Contains(degraded.Message, "registry") {
+		t.Errorf("expected the workload to be degraded by the missing pull secret, got %v", degraded)
+	}
+	if err := r.Get(ctx, client.ObjectKeyFromObject(workload), &corev1.ServiceAccount{}); err != nil {
+		t.Errorf("expected the ServiceAccount to be applied, got %v", err)
+	}
+}
+
+func TestWorkloadsForPullSecret(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler(testWorkload("app:v1"))
+	namespace := *r.Config.Namespace
+	r.Config.ImagePullSecrets.Names = []string{"registry"}
+	r.Config.ImagePullSecrets.Copy = true
+
+	if requests := r.workloadsForPullSecret(ctx, registrySecret(namespace)); len(requests) != 1 || requests[0].Name != "web" {
+		t.Errorf("expected the workload web to be reconciled, got %v", requests)
+	}
+	if requests := r.workloadsForPullSecret(ctx, registrySecret("default")); len(requests) != 0 {
+		t.Errorf("expected secrets of other namespaces to be ignored, got %v", requests)
+	}
+	other := registrySecret(namespace)
+	other.Name = "other"
+	if requests := r.workloadsForPullSecret(ctx, other); len(requests) != 0 {
+		t.Errorf("expected other secrets to be ignored, got %v", requests)
+	}
+}
@@ -0,0 +1,256 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// defaultRevisionHistoryLimit is the number of revisions kept when the
+// workload does not set a limit
+const defaultRevisionHistoryLimit = 10
+
+// revisionSpec returns the spec of the workload that is kept in its revisions.
+// Rolling back and pausing do not make a new revision.
+func revisionSpec(workload platformv1.Workload) platformv1.WorkloadSpec {
+	spec := *workload.Spec.DeepCopy()
+	spec.RollbackTo = nil
+	spec.Paused = false
+
+	return spec
+}
+
+// listRevisions returns the revisions of the workload ordered by their number.
+func (r *WorkloadReconciler) listRevisions(ctx context.Context, workload platformv1.Workload) ([]platformv1.WorkloadRevision, error) {
+	var list platformv1.WorkloadRevisionList
+	if err := r.List(ctx, &list, client.InNamespace(workload.Namespace), client.MatchingLabels(selectorLabels(workload))); err != nil {
+		return nil, err
+	}
+
+	revisions := make([]platformv1.WorkloadRevision, 0, len(list.Items))
+	for _, revision := range list.Items {
+		if metav1.IsControlledBy(&revision, &workload) {
+			revisions = append(revisions, revision)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Spec.Revision < revisions[j].Spec.Revision
+	})
+
+	return revisions, nil
+}
+
+// changedFields returns the names of the fields that differ between two specs.
+func changedFields(previous, current platformv1.WorkloadSpec) []string {
+	var fields []string
+	previousValue, currentValue := reflect.ValueOf(previous), reflect.ValueOf(current)
+	for i := 0; i < previousValue.NumField(); i++ {
+		if equality.Semantic.DeepEqual(previousValue.Field(i).Interface(), currentValue.Field(i).Interface()) {
+			continue
+		}
+		name, _, _ := strings.Cut(previousValue.Type().Field(i).Tag.Get("json"), ",")
+		fields = append(fields, name)
+	}
+
+	return fields
+}
+
+// syncRevisions snapshots the spec of the workload into a WorkloadRevision and
+// prunes the revisions beyond the history limit. It returns the name of the
+// revision of the current spec.
+func (r *WorkloadReconciler) syncRevisions(ctx context.Context, workload *platformv1.Workload) (string, error) {
+	log := log.
+		FromContext(ctx)
+
+	revisions, err := r.listRevisions(ctx, *workload)
+	if err != nil {
+		return "", err
+	}
+
+	spec := revisionSpec(*workload)
+	hash, err := specHash(spec)
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%s", workload.Name, hash)
+
+	var latest *platformv1.WorkloadRevision
+	number := int64(1)
+	if len(revisions) > 0 {
+		latest = &revisions[len(revisions)-1]
+		number = latest.Spec.Revision + 1
+	}
+
+	var existing *platformv1.WorkloadRevision
+	for i := range revisions {
+		if revisions[i].Name == name {
+			existing = &revisions[i]
+		}
+	}
+
+	switch {
+	case existing != nil && existing == latest:
+		// the spec did not change
+	case existing != nil:
+		// an earlier spec is applied again, it becomes the latest revision
+		log.Info("renumbering WorkloadRevision", "revision", name, "number", number)
+		existing.Spec.Revision = number
+		if err := r.Update(ctx, existing); err != nil {
+			return "", err
+		}
+		sort.Slice(revisions, func(i, j int) bool {
+			return revisions[i].Spec.Revision < revisions[j].Spec.Revision
+		})
+	default:
+		log.Info("creating WorkloadRevision", "revision", name, "number", number)
+		revision, err := r.desiredRevision(*workload, name, number, spec, latest)
+		if err != nil {
+			return "", err
+		}
+		if err := r.Create(ctx, &revision); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				return "", err
+			}
+			// the cache did not see the revision created by an earlier reconcile yet
+			log.Info("WorkloadRevision already exists", "revision", name)
+			break
+		}
+		r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonCreated, "Created WorkloadRevision %s", name)
+		revisions = append(revisions, revision)
+	}
+
+	// keep the newest revisions, and the revisions that are rolled out
+	limit := defaultRevisionHistoryLimit
+	if workload.Spec.RevisionHistoryLimit != nil {
+		limit = int(*workload.Spec.RevisionHistoryLimit)
+	}
+	for i := 0; i < len(revisions)-limit; i++ {
+		revision := revisions[i]
+		if revision.Name == name || revision.Name == workload.Status.CurrentRevision {
+			continue
+		}
+
+		log.Info("pruning WorkloadRevision", "revision", revision.Name)
+		if err := r.Delete(ctx, &revision); client.IgnoreNotFound(err) != nil {
+			return "", err
+		}
+	}
+
+	return name, nil
+}
+
+// desiredRevision returns the WorkloadRevision of the spec, summarizing its
+// changes since the previous revision.
+func (r *WorkloadReconciler) desiredRevision(workload platformv1.Workload, name string, number int64, spec platformv1.WorkloadSpec, previous *platformv1.WorkloadRevision) (platformv1.WorkloadRevision, error) {
+	summary := "initial revision"
+	if previous != nil {
+		summary = "no changes"
+		if fields := changedFields(previous.Spec.Template, spec); len(fields) > 0 {
+			summary = "changed " + strings.Join(fields, ", ")
+		}
+	}
+
+	revision := platformv1.WorkloadRevision{
+		TypeMeta: metav1.TypeMeta{APIVersion: platformv1.GroupVersion.String(), Kind: "WorkloadRevision"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: platformv1.WorkloadRevisionSpec{
+			Workload:      workload.Name,
+			Revision:      number,
+			ChangeSummary: summary,
+			Template:      spec,
+		},
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, &revision, r.Scheme); err != nil {
+		return revision, err
+	}
+
+	return revision, nil
+}
+
+// rollback restores the spec of the revision requested by the workload,
+// keeping whether it is paused. It reports whether the workload was updated.
+func (r *WorkloadReconciler) rollback(ctx context.Context, workload *platformv1.Workload) (bool, error) {
+	if workload.Spec.RollbackTo == nil {
+		return false, nil
+	}
+
+	revisions, err := r.listRevisions(ctx, *workload)
+	if err != nil {
+		return false, err
+	}
+
+	// the previous revision is the newest one that is not of the current spec
+	target := *workload.Spec.RollbackTo
+	var revision *platformv1.WorkloadRevision
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if target == 0 && revisions[i].Name != workload.Status.UpdateRevision ||
+			target != 0 && revisions[i].Spec.Revision == target {
+			revision = &revisions[i]
+			break
+		}
+	}
+
+	original := workload.DeepCopy()
+	if revision == nil {
+		r.Recorder.Eventf(workload, corev1.EventTypeWarning, reasonRollbackFailed, "Revision %d of the workload does not exist", target)
+	} else {
+		paused := workload.Spec.Paused
+		workload.Spec = *revision.Spec.Template.DeepCopy()
+		workload.Spec.Paused = paused
+	}
+	workload.Spec.RollbackTo = nil
+
+	if err := r.Update(ctx, workload); err != nil {
+		if !apierrors.IsInvalid(err) && !apierrors.IsForbidden(err) {
+			return false, err
+		}
+
+		// the restored spec is rejected by the validation of the workload
+		r.Recorder.Eventf(workload, corev1.EventTypeWarning, reasonRollbackFailed,
+			"Failed to roll back to revision %d: %s", target, err)
+		*workload = *original
+		workload.Spec.RollbackTo = nil
+		return true, r.Update(ctx, workload)
+	}
+
+	if revision != nil {
+		r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonRolledBack,
+			"Rolled back to revision %d (%s)", revision.Spec.Revision, revision.Name)
+	}
+
+	return true, nil
+}
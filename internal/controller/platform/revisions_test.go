@@ -0,0 +1,174 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/utils/pointer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+func TestRevisionSpecHash(t *testing.T) {
+	workload := testWorkload("app:v1")
+	hash, err := specHash(revisionSpec(*workload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// rolling back and pausing do not make a new revision
+	paused := workload.DeepCopy()
+	paused.Spec.Paused = true
+	paused.Spec.RollbackTo = pointer.Int64(1)
+	if got, _ := specHash(revisionSpec(*paused)); got != hash {
+		t.Errorf("expected the hash to ignore paused and rollbackTo, got %s and %s", got, hash)
+	}
+
+	for name, change := range map[string]func(*platformv1.Workload){
+		"image":       func(w *platformv1.Workload) { w.Spec.Container.Image = "app:v2" },
+		"replicas":    func(w *platformv1.Workload) { w.Spec.Replicas = pointer.Int32(5) },
+		"driftPolicy": func(w *platformv1.Workload) { w.Spec.DriftPolicy = platformv1.DriftPolicyReport },
+	} {
+		updated := workload.DeepCopy()
+		change(updated)
+		if got, _ := specHash(revisionSpec(*updated)); got == hash {
+			t.Errorf("expected a new %s to change the hash", name)
+		}
+	}
+}
+
+func TestSyncRevisions(t *testing.T) {
+	ctx := context.Background()
+	workload := testWorkload("app:v1")
+	r := newTestReconciler(workload)
+
+	expect := func(workload *platformv1.Workload, wantNumber int64, wantSummary string) string {
+		t.Helper()
+		name, err := r.syncRevisions(ctx, workload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var revision platformv1.WorkloadRevision
+		if err := r.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, &revision); err != nil {
+			t.Fatal(err)
+		}
+		if revision.Spec.Revision != wantNumber || revision.Spec.ChangeSummary != wantSummary {
+			t.Errorf("expected revision %d (%s), got %d (%s)", wantNumber, wantSummary,
+				revision.Spec.Revision, revision.Spec.ChangeSummary)
+		}
+		return name
+	}
+
+	first := expect(workload, 1, "initial revision")
+	if again := expect(workload, 1, "initial revision"); again != first {
+		t.Errorf("expected the unchanged spec to keep revision %s, got %s", first, again)
+	}
+
+	updated := testWorkload("app:v2")
+	second := expect(updated, 2, "changed container")
+	if second == first {
+		t.Error("expected a new revision for a new image")
+	}
+
+	// reverting the image renumbers the first revision
+	if reverted := expect(workload, 3, "initial revision"); reverted != first {
+		t.Errorf("expected the first revision to be reused, got %s", reverted)
+	}
+
+	revisions, err := r.listRevisions(ctx, *workload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Errorf("expected 2 revisions, got %d", len(revisions))
+	}
+}
+
+func TestSyncRevisionsStaleCache(t *testing.T) {
+	ctx := context.Background()
+	workload := testWorkload("app:v1")
+	r := newTestReconciler(workload)
+	if _, err := r.syncRevisions(ctx, workload); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cache did not see the revision yet
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			return nil
+		},
+	})
+	if _, err := r.syncRevisions(ctx, workload); err != nil {
+		t.Errorf("expected an existing revision to be tolerated, got %v", err)
+	}
+}
+
+func TestRollback(t *testing.T) {
+	ctx := context.Background()
+	workload := testWorkload("app:v1")
+	workload.Spec.Config = &platformv1.ConfigSpec{Env: map[string]string{"MODE": "v1"}}
+	r := newTestReconciler(workload)
+
+	if err := r.Get(ctx, client.ObjectKeyFromObject(workload), workload); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.syncRevisions(ctx, workload); err != nil {
+		t.Fatal(err)
+	}
+	workload.Spec.Container.Image = "app:v2"
+	workload.Spec.Config = nil
+	if err := r.Update(ctx, workload); err != nil {
+		t.Fatal(err)
+	}
+	updateRevision, err := r.syncRevisions(ctx, workload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	workload.Status.UpdateRevision = updateRevision
+
+	// the workload was scaled and paused after the revision
+	workload.Spec.Replicas = pointer.Int32(6)
+	workload.Spec.Paused = true
+	workload.Spec.RollbackTo = pointer.Int64(0)
+
+	updated, err := r.rollback(ctx, workload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated {
+		t.Fatal("expected the workload to be updated")
+	}
+
+	var got platformv1.Workload
+	if err := r.Get(ctx, client.ObjectKeyFromObject(workload), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Spec.RollbackTo != nil {
+		t.Error("expected rollbackTo to be cleared")
+	}
+	if got.Spec.Container.Image != "app:v1" || got.Spec.Config == nil || got.Spec.Config.Env["MODE"] != "v1" ||
+		*got.Spec.Replicas != 4 {
+		t.Errorf("expected the spec of revision 1 to be restored, got %v", got.Spec)
+	}
+	if !got.Spec.Paused {
+		t.Error("expected the workload to stay paused")
+	}
+}
@@ -0,0 +1,213 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// headlessServiceName returns the name of the Service governing the StatefulSet of a workload.
+func headlessServiceName(workload platformv1.Workload) string {
+	return fmt.Sprintf("%s-headless", workload.Name)
+}
+
+// volumeClaimTemplates returns the persistent volume claims of the pods of the workload.
+func volumeClaimTemplates(workload platformv1.Workload) []corev1.PersistentVolumeClaim {
+	claims := make([]corev1.PersistentVolumeClaim, 0, len(workload.Spec.Storage.Volumes))
+	for _, volume := range workload.Spec.Storage.Volumes {
+		accessMode := volume.AccessMode
+		if accessMode == "" {
+			accessMode = corev1.ReadWriteOnce
+		}
+
+		claims = append(claims, corev1.PersistentVolumeClaim{
+			TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "PersistentVolumeClaim"},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   volume.Name,
+				Labels: selectorLabels(workload),
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{
+				AccessModes:      []corev1.PersistentVolumeAccessMode{accessMode},
+				StorageClassName: volume.StorageClassName,
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceStorage: volume.Size},
+				},
+			},
+		})
+	}
+
+	return claims
+}
+
+// retentionPolicy returns the StatefulSet retention policy type of the claims.
+func retentionPolicy(policy platformv1.VolumeRetentionPolicy) appsv1.PersistentVolumeClaimRetentionPolicyType {
+	if policy == platformv1.VolumeRetentionPolicyDelete {
+		return appsv1.DeletePersistentVolumeClaimRetentionPolicyType
+	}
+
+	return appsv1.RetainPersistentVolumeClaimRetentionPolicyType
+}
+
+// desiredStatefulSet returns the StatefulSet of a workload with storage. The
+// volume claim templates of an existing StatefulSet can not be changed, so
+// they are kept and its claims are expanded by resizeClaims instead.
+func (r *WorkloadReconciler) desiredStatefulSet(ctx context.Context, workload platformv1.Workload, podTemplate corev1.PodTemplateSpec) (appsv1.StatefulSet, error) {
+	// the replicas of an autoscaled workload are owned by the HorizontalPodAutoscaler
+	replicas := workload.Spec.Replicas
+	if workload.Spec.Autoscaling != nil {
+		replicas = nil
+	}
+
+	claims := volumeClaimTemplates(workload)
+	var existing appsv1.StatefulSet
+	if err := r.Get(ctx, client.ObjectKeyFromObject(&workload), &existing); client.IgnoreNotFound(err) != nil {
+		return appsv1.StatefulSet{}, err
+	} else if err == nil && metav1.IsControlledBy(&existing, &workload) {
+		claims = existing.Spec.VolumeClaimTemplates
+	}
+
+	// copy the containers, they share their backing array with the caller
+	podTemplate.Spec.Containers = append([]corev1.Container{}, podTemplate.Spec.Containers...)
+	container := &podTemplate.Spec.Containers[0]
+	container.VolumeMounts = append([]corev1.VolumeMount{}, container.VolumeMounts...)
+	for _, volume := range workload.Spec.Storage.Volumes {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      volume.Name,
+			MountPath: volume.MountPath,
+		})
+	}
+
+	statefulSet := appsv1.StatefulSet{
+		TypeMeta: metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "StatefulSet"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workload.Name,
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    replicas,
+			ServiceName: headlessServiceName(workload),
+			Selector: &metav1.LabelSelector{
+				MatchLabels: selectorLabels(workload),
+			},
+			Template:             podTemplate,
+			VolumeClaimTemplates: claims,
+			PersistentVolumeClaimRetentionPolicy: &appsv1.StatefulSetPersistentVolumeClaimRetentionPolicy{
+				WhenDeleted: retentionPolicy(workload.Spec.Storage.WhenDeleted),
+				WhenScaled:  retentionPolicy(workload.Spec.Storage.WhenScaled),
+			},
+		},
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, &statefulSet, r.Scheme); err != nil {
+		return statefulSet, err
+	}
+
+	return statefulSet, nil
+}
+
+// desiredHeadlessService returns the Service governing the StatefulSet of a
+// workload, which gives its pods stable DNS names.
+func (r *WorkloadReconciler) desiredHeadlessService(workload platformv1.Workload) (corev1.Service, error) {
+	var ports []corev1.ServicePort
+	for _, port := range workload.Spec.Container.Ports {
+		ports = append(ports, corev1.ServicePort{
+			Name:     port.Name,
+			Protocol: port.Protocol,
+			Port:     port.ContainerPort,
+		})
+	}
+
+	service := corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      headlessServiceName(workload),
+			Namespace: workload.Namespace,
+			Labels:    selectorLabels(workload),
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP:                corev1.ClusterIPNone,
+			Selector:                 selectorLabels(workload),
+			Ports:                    ports,
+			PublishNotReadyAddresses: true,
+		},
+	}
+
+	// always set the controller reference so that we know which object owns this.
+	if err := ctrl.SetControllerReference(&workload, &service, r.Scheme); err != nil {
+		return service, err
+	}
+
+	return service, nil
+}
+
+// resizeClaims expands the persistent volume claims of the StatefulSet of the
+// workload that are smaller than their volume. Claims are never shrunk.
+func (r *WorkloadReconciler) resizeClaims(ctx context.Context, workload *platformv1.Workload, statefulSet *appsv1.StatefulSet) error {
+	log := log.
+		FromContext(ctx)
+
+	var claims corev1.PersistentVolumeClaimList
+	if err := r.List(ctx, &claims, client.InNamespace(workload.Namespace), client.MatchingLabels(selectorLabels(*workload))); err != nil {
+		return err
+	}
+
+	for _, volume := range workload.Spec.Storage.Volumes {
+		// claims of a StatefulSet are named <volume>-<statefulset>-<ordinal>
+		prefix := fmt.Sprintf("%s-%s-", volume.Name, statefulSet.Name)
+
+		for i := range claims.Items {
+			claim := &claims.Items[i]
+			if !strings.HasPrefix(claim.Name, prefix) {
+				continue
+			}
+
+			current := claim.Spec.Resources.Requests[corev1.ResourceStorage]
+			if current.Cmp(volume.Size) >= 0 {
+				continue
+			}
+
+			log.Info("expanding persistent volume claim", "name", claim.Name, "from", current.String(), "to", volume.Size.String())
+			patch := client.MergeFrom(claim.DeepCopy())
+			if claim.Spec.Resources.Requests == nil {
+				claim.Spec.Resources.Requests = corev1.ResourceList{}
+			}
+			claim.Spec.Resources.Requests[corev1.ResourceStorage] = volume.Size
+			if err := r.Patch(ctx, claim, patch); err != nil {
+				r.Recorder.Eventf(workload, corev1.EventTypeWarning, reasonResizeFailed,
+					"Failed to expand PersistentVolumeClaim %s to %s: %s", claim.Name, volume.Size.String(), err)
+				return err
+			}
+			r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonResized,
+				"Expanding PersistentVolumeClaim %s to %s", claim.Name, volume.Size.String())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	platformv1 "mydev.org/platform-operator/api/platform/v1"
+)
+
+// storageWorkload returns the workload "web" with a data volume of the size.
+func storageWorkload(size string) *platformv1.Workload {
+	workload := testWorkload("app:v1")
+	workload.Spec.Container.Ports = []corev1.ContainerPort{{Name: "http", ContainerPort: 8080, Protocol: corev1.ProtocolTCP}}
+	workload.Spec.Storage = &platformv1.StorageSpec{
+		Volumes:     []platformv1.StorageVolume{{Name: "data", MountPath: "/data", Size: resource.MustParse(size)}},
+		WhenDeleted: platformv1.VolumeRetentionPolicyDelete,
+	}
+
+	return workload
+}
+
+// volumeClaim returns the claim of the data volume of the pod of the StatefulSet "web".
+func volumeClaim(name, size string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", Labels: map[string]string{workloadLabel: "web"}},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(size)},
+			},
+		},
+	}
+}
+
+func TestDesiredStatefulSet(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler()
+	workload := storageWorkload("1Gi")
+	template := testPodTemplate("app:v1")
+
+	statefulSet, err := r.desiredStatefulSet(ctx, *workload, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOwned(t, &statefulSet)
+	if statefulSet.Spec.ServiceName != "web-headless" {
+		t.Errorf("expected the StatefulSet to be governed by web-headless, got %s", statefulSet.Spec.ServiceName)
+	}
+	mounts := statefulSet.Spec.Template.Spec.Containers[0].VolumeMounts
+	if len(mounts) != 1 || mounts[0].Name != "data" || mounts[0].MountPath != "/data" {
+		t.Errorf("expected the data volume to be mounted at /data, got %v", mounts)
+	}
+	if len(template.Spec.Containers[0].VolumeMounts) != 0 {
+		t.Error("expected the pod template of the caller to be left alone")
+	}
+	claims := statefulSet.Spec.VolumeClaimTemplates
+	if len(claims) != 1 || claims[0].Name != "data" || claims[0].Spec.AccessModes[0] != corev1.ReadWriteOnce ||
+		claims[0].Spec.Resources.Requests.Storage().String() != "1Gi" {
+		t.Errorf("expected a 1Gi ReadWriteOnce claim of data, got %v", claims)
+	}
+	if policy := statefulSet.Spec.PersistentVolumeClaimRetentionPolicy; policy.WhenDeleted != appsv1.DeletePersistentVolumeClaimRetentionPolicyType ||
+		policy.WhenScaled != appsv1.RetainPersistentVolumeClaimRetentionPolicyType {
+		t.Errorf("expected the claims to be deleted with the workload and retained on scale down, got %v", policy)
+	}
+
+	// the claim templates of an existing StatefulSet can not be changed
+	if err := r.Create(ctx, &statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	resized, err := r.desiredStatefulSet(ctx, *storageWorkload("2Gi"), template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resized.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests.Storage().String(); got != "1Gi" {
+		t.Errorf("expected the claim templates of the StatefulSet to be kept, got %s", got)
+	}
+}
+
+func TestDesiredHeadlessService(t *testing.T) {
+	service, err := newTestReconciler().desiredHeadlessService(*storageWorkload("1Gi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectOwned(t, &service)
+	if service.Name != "web-headless" || service.Spec.ClusterIP != corev1.ClusterIPNone || !service.Spec.PublishNotReadyAddresses {
+		t.Errorf("expected the headless Service web-headless, got %s with %v", service.Name, service.Spec)
+	}
+	if len(service.Spec.Ports) != 1 || service.Spec.Ports[0].Name != "http" || service.Spec.Ports[0].Port != 8080 {
+		t.Errorf("expected the container port http, got %v", service.Spec.Ports)
+	}
+}
+
+func TestResizeClaims(t *testing.T) {
+	ctx := context.Background()
+	workload := storageWorkload("2Gi")
+	r := newTestReconciler(
+		volumeClaim("data-web-0", "1Gi"),
+		volumeClaim("data-web-1", "5Gi"),
+		volumeClaim("logs-web-0", "1Gi"),
+	)
+	statefulSet := &appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}}
+
+	if err := r.resizeClaims(ctx, workload, statefulSet); err != nil {
+		t.Fatal(err)
+	}
+	expectEvent(t, r, reasonResized)
+
+	for name, want := range map[string]string{
+		"data-web-0": "2Gi",
+		// claims are never shrunk
+		"data-web-1": "5Gi",
+		// claims of other volumes are left alone
+		"logs-web-0": "1Gi",
+	} {
+		var claim corev1.PersistentVolumeClaim
+		if err := r.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, &claim); err != nil {
+			t.Fatal(err)
+		}
+		if got := claim.Spec.Resources.Requests.Storage().String(); got != want {
+			t.Errorf("expected %s to request %s, got %s", name, want, got)
+		}
+	}
+}
@@ -19,39 +19,70 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	configv1alpha1 "mydev.org/platform-operator/api/config/v1alpha1"
 	platformv1 "mydev.org/platform-operator/api/platform/v1"
 
+	"k8s.io/client-go/tools/record"
 	ref "k8s.io/client-go/tools/reference"
 )
 
-//const workloadFinalizer = "platform.mydev.org/finalizer"
+// workloadFinalizer runs the deletion policy before a Workload is removed
+const workloadFinalizer = "platform.mydev.org/finalizer"
 
-// Definitions to manage status conditions
-const (
-	// typeAvailableWorkload represents the status of the entire Workload reconciliation
-	typeAvailableWorkload = "Available"
-)
+// fieldOwner is the field manager used for server-side apply of owned objects
+const fieldOwner = "workload-controller"
 
 // WorkloadReconciler reconciles a Workload object
 type WorkloadReconciler struct {
 	client.Client
-	Scheme *runtime.Scheme
+	Scheme   *runtime.Scheme
+	Config   configv1alpha1.OperatorConfig
+	Recorder record.EventRecorder
 }
 
 //+kubebuilder:rbac:groups=platform.mydev.org,resources=workloads,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=platform.mydev.org,resources=workloads/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=platform.mydev.org,resources=workloads/finalizers,verbs=update
-//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;get;patch
+//+kubebuilder:rbac:groups=platform.mydev.org,resources=workloadrevisions,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+//+kubebuilder:rbac:groups=core,resources=serviceaccounts,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=roles,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=rbac.authorization.k8s.io,resources=rolebindings,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=core,resources=persistentvolumeclaims,verbs=get;list;watch;update;patch
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch;create;update;patch;delete
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -87,10 +118,8 @@ func (r *WorkloadReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		// There are valid condition statuses. "ConditionTrue" means a resource is in the condition.
 		// "ConditionFalse" means a resource is not in the condition. "ConditionUnknown" means kubernetes
 		// can't decide if a resource is in the condition or not.
-		meta.SetStatusCondition(&workload.Status.Conditions, metav1.Condition{Type: typeAvailableWorkload,
-			Status: metav1.ConditionUnknown, Reason: "Reconciling",
-			Message: "Starting reconciliation",
-		})
+		setCondition(&workload, typeReadyWorkload, metav1.ConditionUnknown, reasonReconciling, "Starting reconciliation")
+		setCondition(&workload, typeProgressingWorkload, metav1.ConditionTrue, reasonReconciling, "Starting reconciliation")
 
 		if err := r.Status().Update(ctx, &workload); err != nil {
 			log.Error(err, "Failed to update Workload status")
@@ -108,6 +137,124 @@ func (r *WorkloadReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		}
 	}
 
+	// Check if the Workload instance is marked to be deleted, which is
+	// indicated by the deletion timestamp being set.
+	if !workload.GetDeletionTimestamp().IsZero() {
+		if controllerutil.ContainsFinalizer(&workload, workloadFinalizer) {
+			log.Info("Performing Finalizer Operations for Workload before delete CR",
+				"deletionPolicy", workload.Spec.DeletionPolicy)
+
+			// Let's add here a status "Degraded" to define that this resource begin its process to be terminated.
+			message := fmt.Sprintf("Performing finalizer operations for the custom resource: %s ", workload.Name)
+			setCondition(&workload, typeReadyWorkload, metav1.ConditionFalse, reasonFinalizing, message)
+			setCondition(&workload, typeDegradedWorkload, metav1.ConditionUnknown, reasonFinalizing, message)
+
+			if err := r.Status().Update(ctx, &workload); err != nil {
+				log.Error(err, "Failed to update Workload status")
+				return ctrl.Result{}, err
+			}
+
+			// Perform all operations required before remove the finalizer and allow
+			// the Kubernetes API to remove the custom resource.
+			if err := r.finalizeWorkload(ctx, &workload); err != nil {
+				r.Recorder.Eventf(&workload, corev1.EventTypeWarning, reasonFinalizeFailed,
+					"Failed to apply deletion policy %s: %s", workload.Spec.DeletionPolicy, err)
+				log.Error(err, "Failed to perform finalizer operations for Workload")
+				return ctrl.Result{}, err
+			}
+
+			setCondition(&workload, typeDegradedWorkload, metav1.ConditionTrue, reasonFinalizing,
+				fmt.Sprintf("Finalizer operations for custom resource %s name were successfully accomplished", workload.Name))
+
+			if err := r.Status().Update(ctx, &workload); err != nil {
+				log.Error(err, "Failed to update Workload status")
+				return ctrl.Result{}, err
+			}
+
+			log.Info("Removing Finalizer for Workload after successfully perform the operations")
+			controllerutil.RemoveFinalizer(&workload, workloadFinalizer)
+
+			if err := r.Update(ctx, &workload); err != nil {
+				log.Error(err, "Failed to remove finalizer for Workload")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	// Let's add a finalizer. Then, we can define some operations which should
+	// occurs before the custom resource to be deleted.
+	// More info: https://kubernetes.io/docs/concepts/overview/working-with-objects/finalizers
+	if !controllerutil.ContainsFinalizer(&workload, workloadFinalizer) {
+		log.Info("Adding Finalizer for Workload")
+		controllerutil.AddFinalizer(&workload, workloadFinalizer)
+
+		if err := r.Update(ctx, &workload); err != nil {
+			log.Error(err, "Failed to update custom resource to add finalizer")
+			return ctrl.Result{}, err
+		}
+	}
+
+	// leave the objects of a paused workload alone, they are corrected once it is resumed
+	if reason := pausedReason(workload); reason != "" {
+		if !meta.IsStatusConditionTrue(workload.Status.Conditions, typePausedWorkload) {
+			log.Info("pausing Workload", "reason", reason)
+			r.Recorder.Eventf(&workload, corev1.EventTypeNormal, reasonPaused, "Paused reconciliation (%s)", reason)
+
+			setCondition(&workload, typePausedWorkload, metav1.ConditionTrue, reason,
+				fmt.Sprintf("Reconciliation of custom resource (%s) is paused", workload.Name))
+			now := metav1.Now()
+			workload.Status.LastReconcileTime = &now
+
+			if err := r.Status().Update(ctx, &workload); err != nil {
+				log.Error(err, "Failed to update Workload status")
+				return ctrl.Result{}, err
+			}
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	// the objects changed by hand while the workload was paused are corrected
+	// once it is resumed, whatever its drift policy
+	correctDrift := !reportsDrift(workload)
+	if meta.FindStatusCondition(workload.Status.Conditions, typePausedWorkload) != nil {
+		log.Info("resuming Workload")
+		correctDrift = true
+		r.Recorder.Eventf(&workload, corev1.EventTypeNormal, reasonResumed, "Resumed reconciliation")
+		meta.RemoveStatusCondition(&workload.Status.Conditions, typePausedWorkload)
+	}
+
+	// restore the spec of an earlier revision, the update starts another reconciliation
+	if rolledBack, err := r.rollback(ctx, &workload); err != nil || rolledBack {
+		if err != nil {
+			log.Error(err, "Failed to roll back Workload")
+		}
+		return ctrl.Result{}, err
+	}
+
+	// keep the observed status to tell whether this reconciliation changed it
+	observedStatus := workload.Status.DeepCopy()
+
+	// create WorkloadRevision object
+	log.Info("reconciling WorkloadRevision object")
+	updateRevision, err := r.syncRevisions(ctx, &workload)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	workload.Status.UpdateRevision = updateRevision
+
+	// create image pull Secret objects
+	log.Info("reconciling image pull Secret objects")
+	pullSecrets, missingPullSecrets, err := r.desiredImagePullSecrets(ctx, workload)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(missingPullSecrets) > 0 {
+		r.Recorder.Eventf(&workload, corev1.EventTypeWarning, reasonPullSecretMissing,
+			"Image pull secrets %s do not exist in namespace %s", strings.Join(missingPullSecrets, ", "), *r.Config.Namespace)
+	}
+
 	// create ServiceAccount object
 	log.Info("reconciling ServiceAccount object")
 	svcAccount, err := r.desiredServiceAccount(workload)
@@ -115,18 +262,280 @@ func (r *WorkloadReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, err
 	}
 
+	// create Role and RoleBinding objects, the permissions must not escalate
+	// beyond the allowlist of the operator. Denied permissions grant nothing,
+	// so that the permissions granted before are pruned.
+	log.Info("reconciling Role and RoleBinding objects")
+	var role *rbacv1.Role
+	var roleBindings []rbacv1.RoleBinding
+	permissionsErr := r.validatePermissions(workload)
+	if permissionsErr != nil {
+		r.Recorder.Eventf(&workload, corev1.EventTypeWarning, reasonPermissionDenied, "Permissions are not allowed: %s", permissionsErr)
+	} else {
+		role, err = r.desiredRole(workload)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		roleBindings, err = r.desiredRoleBindings(workload, svcAccount, role)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// create ConfigMap object
+	log.Info("reconciling ConfigMap object")
+	configMap, err := r.desiredConfigMap(workload)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	configHash, missingSecrets, err := r.configHash(ctx, workload, configMap)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if len(missingSecrets) > 0 {
+		r.Recorder.Eventf(&workload, corev1.EventTypeWarning, reasonSecretMissing,
+			"Secrets %s referenced by the workload do not exist", strings.Join(missingSecrets, ", "))
+	}
+
+	// create Deployment, Job or CronJob object
+	var podTemplate corev1.PodTemplateSpec
+	if runsPods(workload) {
+		podTemplate = r.desiredPodTemplate(workload, svcAccount, configHash)
+	}
+
+	var deployment, canary, inactive, retired *appsv1.Deployment
+	var handledAnnotations []string
+	var requeueAfter time.Duration
+	var statefulSet *appsv1.StatefulSet
+	var headlessService *corev1.Service
+	var job *batchv1.Job
+	var cronJob *batchv1.CronJob
+	switch {
+	case !runsPods(workload):
+		log.Info("skipping pods of Workload without container")
+	case workload.Spec.Storage != nil:
+		log.Info("reconciling StatefulSet object")
+		var desired appsv1.StatefulSet
+		desired, err = r.desiredStatefulSet(ctx, workload, podTemplate)
+		statefulSet = &desired
+		if err == nil {
+			var desiredService corev1.Service
+			desiredService, err = r.desiredHeadlessService(workload)
+			headlessService = &desiredService
+		}
+	case workload.Spec.Kind == platformv1.WorkloadKindJob:
+		log.Info("reconciling Job object")
+		job, err = r.desiredJob(workload, podTemplate)
+	case workload.Spec.Kind == platformv1.WorkloadKindCronJob:
+		log.Info("reconciling CronJob object")
+		cronJob, err = r.desiredCronJob(workload, podTemplate)
+	case usesBlueGreen(workload):
+		log.Info("reconciling blue/green Deployment objects")
+		deployment, inactive, handledAnnotations, err = r.blueGreenRollout(ctx, &workload, podTemplate)
+	default:
+		log.Info("reconciling Deployment object")
+		var desired appsv1.Deployment
+		desired, err = r.desiredDeployment(workload, podTemplate)
+		deployment = &desired
+		if err == nil {
+			canary, requeueAfter, err = r.canaryRollout(ctx, &workload, deployment)
+		}
+	}
+	if err == nil && deployment != nil {
+		retired, err = r.retiredDeployment(ctx, workload, deployment)
+	}
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !usesBlueGreen(workload) {
+		workload.Status.BlueGreen = nil
+	}
+
+	// create HorizontalPodAutoscaler object
+	var scaleTarget client.Object
+	switch {
+	case deployment != nil:
+		scaleTarget = deployment
+	case statefulSet != nil:
+		scaleTarget = statefulSet
+	}
+
+	var hpa *autoscalingv2.HorizontalPodAutoscaler
+	if workload.Spec.Autoscaling != nil && scaleTarget != nil {
+		log.Info("reconciling HorizontalPodAutoscaler object")
+		desired, err := r.desiredHorizontalPodAutoscaler(workload, scaleTarget)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		hpa = &desired
+
+		if err := r.handOverReplicas(ctx, scaleTarget); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// create PodDisruptionBudget object
+	log.Info("reconciling PodDisruptionBudget object", "availability", workload.Spec.Availability)
+	pdb, err := r.desiredPodDisruptionBudget(workload)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// create NetworkPolicy object
+	log.Info("reconciling NetworkPolicy object")
+	networkPolicy, err := r.desiredNetworkPolicy(workload)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// create Service object
+	var service *corev1.Service
+	if workload.Spec.Service != nil {
+		log.Info("reconciling Service object")
+		desired, err := r.desiredService(workload)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		service = &desired
+
+		// the pods of the Deployment from before the switch to blue/green have no color
+		if retired != nil {
+			delete(service.Spec.Selector, colorLabel)
+		}
+
+		// the pods of the stable Deployment are labeled with their track by a rollout
+		if r.weightedRouting(workload) {
+			tracked, err := r.stableTracked(ctx, workload)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+			if !tracked {
+				delete(service.Spec.Selector, trackLabel)
+			}
+		}
+	}
+
+	var canaryService, previewService *corev1.Service
+	if canary != nil && r.weightedRouting(workload) {
+		desired, err := r.desiredCanaryService(workload)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		canaryService = &desired
+	}
+	if service != nil && workload.Status.BlueGreen != nil && workload.Status.BlueGreen.PreviewColor != "" {
+		desired, err := r.desiredPreviewService(workload)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		previewService = &desired
+	}
+
+	// create Ingress or HTTPRoute object
+	var route client.Object
+	if workload.Spec.Ingress != nil {
+		log.Info("reconciling route object", "mode", r.Config.Routing.Mode)
+		route, err = r.desiredRoute(workload)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
 	// APPLY: apply changes to objects in the cluster
-	applyOpts := []client.PatchOption{client.ForceOwnership, client.FieldOwner("workload-controller")}
+	var children []client.Object
+	for i := range pullSecrets {
+		children = append(children, &pullSecrets[i])
+	}
+	children = append(children, &svcAccount)
+	if role != nil {
+		children = append(children, role)
+	}
+	for i := range roleBindings {
+		children = append(children, &roleBindings[i])
+	}
+	if configMap != nil {
+		children = append(children, configMap)
+	}
+	switch {
+	case deployment != nil:
+		children = append(children, deployment)
+		if canary != nil {
+			children = append(children, canary)
+		}
+		if inactive != nil {
+			children = append(children, inactive)
+		}
+		if retired != nil {
+			children = append(children, retired)
+		}
+	case statefulSet != nil:
+		children = append(children, headlessService, statefulSet)
+	case job != nil:
+		children = append(children, job)
+	case cronJob != nil:
+		children = append(children, cronJob)
+	}
+	if hpa != nil {
+		children = append(children, hpa)
+	}
+	if pdb != nil {
+		children = append(children, pdb)
+	}
+	if networkPolicy != nil {
+		children = append(children, networkPolicy)
+	}
+	if service != nil {
+		children = append(children, service)
+	}
+	if canaryService != nil {
+		children = append(children, canaryService)
+	}
+	if previewService != nil {
+		children = append(children, previewService)
+	}
+	if route != nil {
+		children = append(children, route)
+	}
+
+	var drift []string
+	for _, child := range children {
+		childDrift, err := r.applyChild(ctx, &workload, child, correctDrift)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if childDrift != "" {
+			drift = append(drift, childDrift)
+		}
+	}
+	setDriftCondition(&workload, drift, correctDrift)
+
+	// the PodDisruptionBudget is kept when it blocks node drains, but reported
+	if pdb != nil {
+		if replicas, blocked := disruptionsBlocked(workload, pdb); blocked {
+			setCondition(&workload, resourceConditionType(pdb.Kind), metav1.ConditionTrue, reasonDisruptionsBlocked,
+				fmt.Sprintf("PodDisruptionBudget (%s) allows no disruption of %d replica(s), node drains wait until the workload is scaled up",
+					pdb.Name, replicas))
+		}
+	}
 
-	// apply changes for ServiceAccount
-	log.Info("applying changes for ServiceAccount")
-	err = r.Patch(ctx, &svcAccount, client.Apply, applyOpts...)
+	// expand the persistent volume claims of the StatefulSet
+	if statefulSet != nil {
+		if err := r.resizeClaims(ctx, &workload, statefulSet); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	// PRUNE: delete objects of earlier reconciliations that are no longer desired
+	inventory, err := r.inventoryOf(children)
 	if err != nil {
-		// The following implementation will update the status
-		meta.SetStatusCondition(&workload.Status.Conditions, metav1.Condition{Type: typeAvailableWorkload,
-			Status: metav1.ConditionFalse, Reason: "Reconciling",
-			Message: fmt.Sprintf("Failed to create/update the Service Account (%s): (%s)", svcAccount.Name, err),
-		})
+		return ctrl.Result{}, err
+	}
+
+	if err := r.pruneChildren(ctx, &workload, inventory); err != nil {
+		setFailed(&workload, reasonPruneFailed,
+			fmt.Sprintf("Failed to prune objects of custom resource (%s): (%s)", workload.Name, err))
 
 		if err := r.Status().Update(ctx, &workload); err != nil {
 			log.Error(err, "Failed to update Workload status")
@@ -143,20 +552,275 @@ func (r *WorkloadReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	}
 	workload.Status.ServiceAccount = *svcAccountRef
 
-	meta.SetStatusCondition(&workload.Status.Conditions, metav1.Condition{Type: typeAvailableWorkload,
-		Status: metav1.ConditionTrue, Reason: "Reconciling",
-		Message: fmt.Sprintf("ServiceAccount for custom resource (%s) created successfully", workload.Name),
-	})
+	identity, err := r.identityStatus(workload, svcAccount.Namespace, svcAccount.Name)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	workload.Status.Identity = identity
+
+	workload.Status.Deployment = corev1.ObjectReference{}
+	workload.Status.StatefulSet = corev1.ObjectReference{}
+	workload.Status.Job = corev1.ObjectReference{}
+	workload.Status.LastRunTime = nil
+	workload.Status.LastSuccessTime = nil
+	workload.Status.DesiredReplicas = 0
+	workload.Status.Replicas = 0
+	workload.Status.ReadyReplicas = 0
+	workload.Status.AvailableReplicas = 0
+	if deployment != nil {
+		deploymentRef, err := ref.GetReference(r.Scheme, deployment)
+		if err != nil {
+			log.Error(err, "unable to make reference to deployment", "deployment", deployment)
+		}
+		workload.Status.Deployment = *deploymentRef
+		workload.Status.DesiredReplicas = desiredReplicas(deployment)
+		workload.Status.Replicas = deployment.Status.Replicas
+		workload.Status.ReadyReplicas = deployment.Status.ReadyReplicas
+		workload.Status.AvailableReplicas = deployment.Status.AvailableReplicas
+	}
+	if statefulSet != nil {
+		statefulSetRef, err := ref.GetReference(r.Scheme, statefulSet)
+		if err != nil {
+			log.Error(err, "unable to make reference to statefulSet", "statefulSet", statefulSet)
+		}
+		workload.Status.StatefulSet = *statefulSetRef
+		workload.Status.DesiredReplicas = 1
+		if statefulSet.Spec.Replicas != nil {
+			workload.Status.DesiredReplicas = *statefulSet.Spec.Replicas
+		}
+		workload.Status.Replicas = statefulSet.Status.Replicas
+		workload.Status.ReadyReplicas = statefulSet.Status.ReadyReplicas
+		workload.Status.AvailableReplicas = statefulSet.Status.AvailableReplicas
+	}
+	if job != nil {
+		jobRef, err := ref.GetReference(r.Scheme, job)
+		if err != nil {
+			log.Error(err, "unable to make reference to job", "job", job)
+		}
+		workload.Status.Job = *jobRef
+		workload.Status.LastRunTime = job.Status.StartTime
+		workload.Status.LastSuccessTime = job.Status.CompletionTime
+	}
+	if cronJob != nil {
+		cronJobRef, err := ref.GetReference(r.Scheme, cronJob)
+		if err != nil {
+			log.Error(err, "unable to make reference to cronJob", "cronJob", cronJob)
+		}
+		workload.Status.Job = *cronJobRef
+		workload.Status.LastRunTime = cronJob.Status.LastScheduleTime
+		workload.Status.LastSuccessTime = cronJob.Status.LastSuccessfulTime
+	}
+
+	workload.Status.Service = corev1.ObjectReference{}
+	if service != nil {
+		serviceRef, err := ref.GetReference(r.Scheme, service)
+		if err != nil {
+			log.Error(err, "unable to make reference to service", "service", service)
+		}
+		workload.Status.Service = *serviceRef
+	}
+
+	workload.Status.Route = corev1.ObjectReference{}
+	if route != nil {
+		routeRef, err := ref.GetReference(r.Scheme, route)
+		if err != nil {
+			log.Error(err, "unable to make reference to route", "route", route)
+		}
+		workload.Status.Route = *routeRef
+	}
+
+	workload.Status.Inventory = inventory
+
+	appliedKinds := map[string]bool{}
+	for _, child := range inventory {
+		appliedKinds[child.Kind] = true
+	}
+	pruneResourceConditions(&workload, appliedKinds)
+	switch {
+	case deployment != nil:
+		setDeploymentConditions(&workload, deployment)
+		setCanaryConditions(&workload)
+		setBlueGreenConditions(&workload)
+		workload.Status.Summary = fmt.Sprintf("%d/%d", workload.Status.ReadyReplicas, workload.Status.DesiredReplicas)
+	case statefulSet != nil:
+		setStatefulSetConditions(&workload, statefulSet)
+		workload.Status.Summary = fmt.Sprintf("%d/%d", workload.Status.ReadyReplicas, workload.Status.DesiredReplicas)
+	case job != nil:
+		setJobConditions(&workload, job)
+		completions := int32(1)
+		if job.Spec.Completions != nil {
+			completions = *job.Spec.Completions
+		}
+		workload.Status.Summary = fmt.Sprintf("%d/%d", job.Status.Succeeded, completions)
+	case cronJob != nil:
+		setCronJobConditions(&workload, cronJob)
+		workload.Status.Summary = fmt.Sprintf("%d active", len(cronJob.Status.Active))
+	default:
+		// a workload without container is ready once its objects are applied
+		meta.RemoveStatusCondition(&workload.Status.Conditions, typeAvailableWorkload)
+		workload.Status.ObservedGeneration = workload.Generation
+		workload.Status.Summary = ""
+		setSucceeded(&workload)
+	}
+
+	// the other objects are applied, but the pods may fail to pull their image
+	if len(missingPullSecrets) > 0 {
+		setFailed(&workload, reasonPullSecretMissing,
+			fmt.Sprintf("Image pull secrets (%s) of the operator do not exist", strings.Join(missingPullSecrets, ", ")))
+	}
+
+	// the other objects are applied, but the pods can not start without the secrets
+	if len(missingSecrets) > 0 {
+		setFailed(&workload, reasonSecretMissing,
+			fmt.Sprintf("Secrets (%s) referenced by the workload do not exist", strings.Join(missingSecrets, ", ")))
+		if requeueAfter == 0 || requeueAfter > missingSecretsPollInterval {
+			requeueAfter = missingSecretsPollInterval
+		}
+	}
+
+	// the other objects are applied, but the workload runs without permissions
+	if permissionsErr != nil {
+		setFailed(&workload, reasonPermissionDenied,
+			fmt.Sprintf("Permissions of custom resource (%s) are not allowed: (%s)", workload.Name, permissionsErr))
+	}
+
+	if meta.IsStatusConditionTrue(workload.Status.Conditions, typeReadyWorkload) {
+		workload.Status.CurrentRevision = workload.Status.UpdateRevision
+	}
+
+	// only stamp reconciliations that changed the status, as every status
+	// update triggers another reconciliation
+	if !equality.Semantic.DeepEqual(observedStatus, &workload.Status) {
+		now := metav1.Now()
+		workload.Status.LastReconcileTime = &now
+	}
 
 	if err := r.Status().Update(ctx, &workload); err != nil {
 		log.Error(err, "Failed to update Workload status")
 		return ctrl.Result{}, err
 	}
 
+	// the promote and rollback annotations are only removed once the status
+	// records the active color
+	if err := r.removeAnnotations(ctx, &workload, handledAnnotations...); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	// done reconciling
 	log.Info("reconciled Workload")
 
-	return ctrl.Result{}, nil
+	// check a running canary again, even when none of its objects change
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// applyChild server-side applies an object owned by the workload and sets the
+// condition for the kind of the object. When the apply fails, the workload is
+// marked as degraded with the error. Fields of the object changed by others
+// are returned as drift; they are only corrected with correctDrift, i.e. when
+// the drift policy of the workload allows it or the workload was resumed.
+func (r *WorkloadReconciler) applyChild(ctx context.Context, workload *platformv1.Workload, obj client.Object, correctDrift bool) (string, error) {
+	log := log.
+		FromContext(ctx)
+
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+
+	// read the object first, so that creations and changes can be told apart
+	existing := obj.DeepCopyObject().(client.Object)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), existing); client.IgnoreNotFound(err) != nil {
+		return "", err
+	} else if err != nil {
+		existing.SetResourceVersion("")
+	}
+
+	// compare the object with the desired object before applying it, the
+	// fields changed by others are the drift of the object
+	if err := setAppliedHash(obj); err != nil {
+		return "", err
+	}
+	var drift objectDrift
+	if existing.GetResourceVersion() != "" {
+		var err error
+		if drift, err = driftedFields(existing, obj); err != nil {
+			return "", err
+		}
+	}
+
+	var summary string
+	if fields := drift.fields(); len(fields) > 0 {
+		summary = fmt.Sprintf("%s %s: %s", kind, obj.GetName(), strings.Join(fields, ", "))
+	}
+	// the fields added by others are left in place with either drift policy
+	reported := drift.fields()
+	if corrected := fieldNames(drift.modified, drift.removed); correctDrift && len(corrected) > 0 {
+		log.Info("correcting drift", "kind", kind, "name", obj.GetName(), "fields", corrected)
+		r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonDriftCorrected,
+			"Corrected drift of %s %s: %s", kind, obj.GetName(), strings.Join(corrected, ", "))
+		reported = fieldNames(drift.added)
+	}
+	if len(reported) > 0 {
+		log.Info("leaving drift in place", "kind", kind, "name", obj.GetName(), "fields", reported)
+		r.Recorder.Eventf(workload, corev1.EventTypeWarning, reasonDriftDetected,
+			"Detected drift of %s %s: %s", kind, obj.GetName(), strings.Join(reported, ", "))
+	}
+
+	log.Info("applying changes for " + kind)
+	err := r.applyWithoutDrift(ctx, obj, drift, correctDrift)
+	if err != nil {
+		r.Recorder.Eventf(workload, corev1.EventTypeWarning, reasonApplyFailed,
+			"Failed to apply %s %s: %s", kind, obj.GetName(), err)
+
+		// The following implementation will update the status
+		message := fmt.Sprintf("Failed to create/update the %s (%s): (%s)", kind, obj.GetName(), err)
+		setCondition(workload, resourceConditionType(kind), metav1.ConditionFalse, reasonApplyFailed, message)
+		setFailed(workload, reasonApplyFailed, message)
+
+		if err := r.Status().Update(ctx, workload); err != nil {
+			log.Error(err, "Failed to update Workload status")
+			return "", err
+		}
+
+		return "", err
+	}
+
+	setCondition(workload, resourceConditionType(kind), metav1.ConditionTrue, reasonApplied,
+		fmt.Sprintf("%s (%s) applied successfully", kind, obj.GetName()))
+
+	switch existing.GetResourceVersion() {
+	case "":
+		r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonCreated, "Created %s %s", kind, obj.GetName())
+	case obj.GetResourceVersion():
+		// nothing changed
+	default:
+		r.Recorder.Eventf(workload, corev1.EventTypeNormal, reasonUpdated, "Updated %s %s", kind, obj.GetName())
+	}
+
+	return summary, nil
+}
+
+// applyWithoutDrift applies the object. The fields added by others are left
+// in place, as the object does not have them. Without correctDrift, the
+// fields changed or removed by others are left out as well so that they keep
+// their values, and conflicts are not forced.
+func (r *WorkloadReconciler) applyWithoutDrift(ctx context.Context, obj client.Object, drift objectDrift, correctDrift bool) error {
+	if correctDrift {
+		return r.Patch(ctx, obj, client.Apply, client.ForceOwnership, client.FieldOwner(fieldOwner))
+	}
+
+	if len(drift.modified) == 0 && len(drift.removed) == 0 {
+		return r.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldOwner))
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+	deleteFields(content, drift.modified, drift.removed)
+	if err := r.Patch(ctx, &unstructured.Unstructured{Object: content}, client.Apply, client.FieldOwner(fieldOwner)); err != nil {
+		return err
+	}
+
+	// read the object as it is, for the status of the workload
+	return r.Get(ctx, client.ObjectKeyFromObject(obj), obj)
 }
 
 func (r *WorkloadReconciler) ReconcileServiceAccount(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -173,8 +837,36 @@ func (r *WorkloadReconciler) ReconcileServiceAccount(ctx context.Context, req ct
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *WorkloadReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &platformv1.Workload{}, secretRefsIndex, secretRefNames); err != nil {
+		return err
+	}
+
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&platformv1.Workload{}).
 		Owns(&corev1.ServiceAccount{}).
-		Complete(r)
+		Owns(&appsv1.Deployment{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&batchv1.Job{}).
+		Owns(&batchv1.CronJob{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Owns(&networkingv1.NetworkPolicy{}).
+		Owns(&rbacv1.Role{}).
+		Owns(&rbacv1.RoleBinding{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.Secret{}).
+		Owns(&corev1.ConfigMap{}).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.workloadsForSecret))
+
+	// only watch the route kind in use, the Gateway API CRDs may not be installed
+	switch r.Config.Routing.Mode {
+	case configv1alpha1.RoutingModeGatewayAPI:
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(httpRouteGVK)
+		builder = builder.Owns(route)
+	default:
+		builder = builder.Owns(&networkingv1.Ingress{})
+	}
+
+	return builder.Complete(r)
 }
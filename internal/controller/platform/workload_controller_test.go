@@ -0,0 +1,77 @@
+/*
+Copyright 2023.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func TestApplyChildEvents(t *testing.T) {
+	ctx := context.Background()
+	r := newTestReconciler()
+	workload := testWorkload("app:v1")
+
+	apply := func(image string) {
+		t.Helper()
+		if _, err := r.applyChild(ctx, workload, testDeployment("web", image, 4), true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	apply("app:v1")
+	expectEvent(t, r, reasonCreated)
+	if !meta.IsStatusConditionTrue(workload.Status.Conditions, resourceConditionType("Deployment")) {
+		t.Error("expected the Deployment condition to be set")
+	}
+
+	apply("app:v2")
+	expectEvent(t, r, reasonUpdated)
+	condition := meta.FindStatusCondition(workload.Status.Conditions, resourceConditionType("Deployment"))
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != reasonApplied {
+		t.Errorf("expected the Deployment to be applied, got %v", condition)
+	}
+}
+
+func TestApplyChildFailure(t *testing.T) {
+	ctx := context.Background()
+	workload := testWorkload("app:v1")
+	r := newTestReconciler(workload)
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Patch: func(ctx context.Context, c client.WithWatch, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+			return errors.New("admission denied")
+		},
+	})
+
+	if _, err := r.applyChild(ctx, workload, testDeployment("web", "app:v1", 4), true); err == nil {
+		t.Fatal("expected the apply to fail")
+	}
+	expectEvent(t, r, reasonApplyFailed)
+	condition := meta.FindStatusCondition(workload.Status.Conditions, resourceConditionType("Deployment"))
+	if condition == nil || condition.Status != metav1.ConditionFalse || condition.Reason != reasonApplyFailed {
+		t.Errorf("expected the Deployment condition to report the failure, got %v", condition)
+	}
+	if !meta.IsStatusConditionTrue(workload.Status.Conditions, typeDegradedWorkload) {
+		t.Error("expected the workload to be degraded")
+	}
+}